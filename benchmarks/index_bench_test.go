@@ -0,0 +1,69 @@
+package tests
+
+import (
+	"testing"
+	"unsafe"
+
+	"github.com/frankli0324/go-jsontk"
+)
+
+// BenchmarkSelectIndexed runs several queries on the same document, reading
+// it again each time, or jumping over values with an index built once.
+func BenchmarkSelectIndexed(b *testing.B) {
+	b.Run("citm", func(b *testing.B) {
+		benchmarkSelectIndexed(b, citmFixture, "$.performances[-1].id", "$.topicNames['324846100']", "$.venueNames")
+	})
+	b.Run("twitter", func(b *testing.B) {
+		benchmarkSelectIndexed(b, twitterFixture, "$.statuses[*].id", "$.statuses[50].user.name", "$.search_metadata.count")
+	})
+}
+
+func benchmarkSelectIndexed(b *testing.B, s string, paths ...string) {
+	d := unsafe.StringData(s)
+	bb := unsafe.Slice(d, len(s))
+	compiled := make([]*jsontk.Path, len(paths))
+	for i, p := range paths {
+		compiled[i] = jsontk.MustCompilePath(p)
+	}
+	skip := func(iter *jsontk.Iterator) { iter.Skip() }
+	b.Run("iterator", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(s)))
+		var iter jsontk.Iterator
+		for i := 0; i < b.N; i++ {
+			for _, p := range compiled {
+				iter.Reset(bb)
+				if err := iter.SelectCompiled(p, skip); err != nil {
+					panic(err)
+				}
+			}
+		}
+	})
+	b.Run("index", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(s)))
+		x, err := jsontk.BuildIndex(bb)
+		if err != nil {
+			panic(err)
+		}
+		b.ResetTimer()
+		var iter jsontk.Iterator
+		for i := 0; i < b.N; i++ {
+			for _, p := range compiled {
+				iter.ResetIndex(x)
+				if err := iter.SelectCompiled(p, skip); err != nil {
+					panic(err)
+				}
+			}
+		}
+	})
+	b.Run("build", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(s)))
+		for i := 0; i < b.N; i++ {
+			if _, err := jsontk.BuildIndex(bb); err != nil {
+				panic(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,36 @@
+package tests
+
+import (
+	"testing"
+	"unsafe"
+
+	"github.com/frankli0324/go-jsontk"
+)
+
+func BenchmarkIterate(b *testing.B) {
+	b.Run("canada", func(b *testing.B) {
+		benchmarkIterate(b, canadaFixture)
+	})
+	b.Run("citm", func(b *testing.B) {
+		benchmarkIterate(b, citmFixture)
+	})
+	b.Run("twitter", func(b *testing.B) {
+		benchmarkIterate(b, twitterFixture)
+	})
+}
+
+func benchmarkIterate(b *testing.B, s string) {
+	b.StopTimer()
+	b.ReportAllocs()
+	b.SetBytes(int64(len(s)))
+	d := unsafe.StringData(s)
+	bb := unsafe.Slice(d, len(s))
+	b.StartTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if err := jsontk.Iterate(bb, func(jsontk.TokenType, int, int) {}); err != nil {
+				panic(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,63 @@
+package tests
+
+import (
+	"testing"
+	"unsafe"
+
+	"github.com/frankli0324/go-jsontk"
+	jsoniter "github.com/json-iterator/go"
+)
+
+func BenchmarkSkip(b *testing.B) {
+	b.Run("citm", func(b *testing.B) {
+		benchmarkSkip(b, citmFixture)
+	})
+	b.Run("twitter", func(b *testing.B) {
+		benchmarkSkip(b, twitterFixture)
+	})
+}
+
+func benchmarkSkip(b *testing.B, s string) {
+	b.Run("jsoniter", func(b *testing.B) {
+		benchmarkSkipJSONiter(b, s)
+	})
+	b.Run("jsontk", func(b *testing.B) {
+		benchmarkSkipJSONtk(b, s)
+	})
+}
+
+func benchmarkSkipJSONiter(b *testing.B, s string) {
+	b.StopTimer()
+	b.ReportAllocs()
+	b.SetBytes(int64(len(s)))
+	d := unsafe.StringData(s)
+	bb := unsafe.Slice(d, len(s))
+	b.StartTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		iter := jsoniter.NewIterator(jsoniter.ConfigDefault)
+		for pb.Next() {
+			iter.ResetBytes(bb)
+			if iter.Skip(); iter.Error != nil {
+				panic(iter.Error)
+			}
+		}
+	})
+}
+
+func benchmarkSkipJSONtk(b *testing.B, s string) {
+	b.StopTimer()
+	b.ReportAllocs()
+	b.SetBytes(int64(len(s)))
+	d := unsafe.StringData(s)
+	bb := unsafe.Slice(d, len(s))
+	b.StartTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		var iter jsontk.Iterator
+		for pb.Next() {
+			iter.Reset(bb)
+			if iter.Skip(); iter.Error != nil {
+				panic(iter.Error)
+			}
+		}
+	})
+}
@@ -3,6 +3,7 @@ package tests
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 	"testing"
 	"unsafe"
 
@@ -41,6 +42,9 @@ func benchmarkValidate(b *testing.B, s string) {
 	b.Run("jsontk", func(b *testing.B) {
 		benchmarkValidateJSONtk(b, s)
 	})
+	b.Run("jsontk-reader", func(b *testing.B) {
+		benchmarkValidateJSONtkReader(b, s)
+	})
 }
 
 func benchmarkValidateStdJSON(b *testing.B, s string) {
@@ -90,3 +94,21 @@ func benchmarkValidateJSONtk(b *testing.B, s string) {
 		}
 	})
 }
+
+func benchmarkValidateJSONtkReader(b *testing.B, s string) {
+	b.StopTimer()
+	b.ReportAllocs()
+	b.SetBytes(int64(len(s)))
+	b.StartTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		var iter jsontk.Iterator
+		var r strings.Reader
+		for pb.Next() {
+			r.Reset(s)
+			iter.ResetReader(&r)
+			if err := iter.Validate(); err != nil {
+				panic(fmt.Errorf("unexpected error: %s", err))
+			}
+		}
+	})
+}
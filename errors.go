@@ -1,6 +1,11 @@
 package jsontk
 
-import "errors"
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+)
 
 var (
 	ErrPanic              = errors.New("panic occurred")
@@ -11,4 +16,142 @@ var (
 	ErrInvalidParentheses = errors.New("invalid parentheses")
 	ErrStandardViolation  = errors.New("json not compliant to RFC8259") // for some simple validations
 	ErrInvalidJsonpath    = errors.New("invalid jsonpath")
+	ErrInvalidPointer     = errors.New("invalid json pointer")
+	ErrInvalidPatch       = errors.New("invalid json patch")
+	ErrPathNotFound       = errors.New("path not found")
+	ErrTestFailed         = errors.New("test failed")
+	ErrDuplicateKey       = errors.New("duplicate object key")
+	ErrLimitExceeded      = errors.New("limit exceeded")
 )
+
+// SyntaxError describes where the input is malformed. It wraps one of the
+// sentinel errors above, so errors.Is works on it as before.
+type SyntaxError struct {
+	Err      error       // the wrapped sentinel error
+	Offset   int         // byte offset of the error in the input
+	Line     int         // 1-based line of Offset
+	Column   int         // 1-based column of Offset, counted in bytes
+	Expected []TokenType // token types acceptable at Offset, nil if a separator or EOF is expected
+	Found    TokenType   // token type found at Offset, INVALID on EOF or non-token bytes
+	Path     string      // normalized JSONPath of the innermost container being read
+
+	msg   string
+	owned bool     // Path is already relative to the container that raised the error
+	segs  [][]byte // segments to be prepended to Path, innermost first
+}
+
+func newSyntaxError(err error, offset int, msg string, expected ...TokenType) *SyntaxError {
+	return &SyntaxError{Err: err, Offset: offset, msg: msg, Expected: expected}
+}
+
+func (e *SyntaxError) Error() string {
+	var sb strings.Builder
+	sb.WriteString(e.Err.Error())
+	sb.WriteString(" at ")
+	sb.WriteString(strconv.Itoa(e.Offset))
+	sb.WriteString(" (line ")
+	sb.WriteString(strconv.Itoa(e.Line))
+	sb.WriteString(", column ")
+	sb.WriteString(strconv.Itoa(e.Column))
+	sb.WriteByte(')')
+	if e.msg != "" {
+		sb.WriteString(", ")
+		sb.WriteString(e.msg)
+	}
+	if e.Path != "" && e.Path != "$" {
+		sb.WriteString(", in ")
+		sb.WriteString(e.Path)
+	}
+	return sb.String()
+}
+
+func (e *SyntaxError) Unwrap() error {
+	return e.Err
+}
+
+// locate fills in the position of e, whose Offset is relative to data.
+func (e *SyntaxError) locate(data []byte) *SyntaxError {
+	pos := e.Offset
+	if pos > len(data) {
+		pos = len(data)
+	}
+	if pos < len(data) {
+		e.Found = typMap[data[pos]]
+	}
+	e.Line = bytes.Count(data[:pos], []byte{'\n'}) + 1
+	e.Column = pos - bytes.LastIndexByte(data[:pos], '\n')
+	return e
+}
+
+// prependPath prepends seg to the path of a syntax error raised while reading
+// a member of a container. Errors not raised by a container belong to the
+// innermost container reading them, so the first one doesn't prepend.
+// Segments are only joined into Path by the outermost container, so that
+// deeply nested errors take linear time.
+func prependPath(err error, seg func([]byte) []byte, outermost bool) {
+	var e *SyntaxError
+	if !errors.As(err, &e) {
+		return
+	}
+	if !e.owned {
+		e.owned = true
+	} else {
+		e.segs = append(e.segs, seg(nil))
+	}
+	if outermost && len(e.segs) > 0 {
+		path := []byte{'$'}
+		for i := len(e.segs) - 1; i >= 0; i-- {
+			path = append(path, e.segs[i]...)
+		}
+		e.Path, e.segs = string(append(path, e.Path[1:]...)), nil
+	}
+}
+
+// pathAt returns the normalized path of the innermost container of data
+// enclosing offset.
+func pathAt(data []byte, offset int) string {
+	type frame struct {
+		key []byte // nil for arrays
+		idx int
+	}
+	var stack []frame
+	for i := skip(data, 0); i < offset && i < len(data); i = skip(data, i) {
+		switch data[i] {
+		case '{':
+			stack = append(stack, frame{key: data[i:i]})
+		case '[':
+			stack = append(stack, frame{})
+		case '}', ']':
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		case ',':
+			if len(stack) > 0 && stack[len(stack)-1].key == nil {
+				stack[len(stack)-1].idx++
+			}
+		case '"':
+			_, length, err := next(data, i)
+			if err != nil {
+				return "$"
+			}
+			if j := skip(data, i+length); len(stack) > 0 && stack[len(stack)-1].key != nil && j < len(data) && data[j] == ':' {
+				stack[len(stack)-1].key = data[i : i+length]
+			}
+			i += length
+			continue
+		}
+		i++
+	}
+	if len(stack) > 0 {
+		stack = stack[:len(stack)-1]
+	}
+	path := []byte{'$'}
+	for _, f := range stack {
+		if f.key == nil {
+			path = appendNormalizedIndex(path, f.idx)
+		} else {
+			path = appendNormalizedKey(path, f.key)
+		}
+	}
+	return string(path)
+}
@@ -0,0 +1,24 @@
+package jsontk_test
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/frankli0324/go-jsontk"
+)
+
+func ExampleIterator_Bytes() {
+	var iter jsontk.Iterator
+	iter.ResetReader(strings.NewReader(`{"a": [{"b": 1}, {"b": [2, 3]}], "c": {"b": {}}}`))
+	err := iter.Select("$..b", func(iter *jsontk.Iterator) {
+		_, loc, length := iter.Skip()
+		fmt.Printf("%s\n", iter.Bytes(loc, length))
+	})
+	if err != nil {
+		panic(err)
+	}
+	// Output:
+	// 1
+	// [2, 3]
+	// {}
+}
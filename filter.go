@@ -0,0 +1,682 @@
+package jsontk
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// filterSelector implements the filter selector of RFC 9535, section 2.3.5.
+// Each child is evaluated in place before being selected, so only the child
+// itself needs to be kept in the window while streaming.
+type filterSelector struct {
+	expr logicalExpr
+	root bool // expr contains queries relative to the root
+}
+
+func (f *filterSelector) SelectArr(idx int, iter *Iterator) bool {
+	return f.match(iter)
+}
+func (f *filterSelector) SelectObj(key *Token, iter *Iterator) bool {
+	return f.match(iter)
+}
+func (f *filterSelector) appendTo(dst []byte) []byte {
+	return f.expr.appendTo(append(dst, '?'))
+}
+
+func (f *filterSelector) match(iter *Iterator) bool {
+	save := iter.head
+	iter.pin++
+	_, loc, length := iter.Skip()
+	ok := iter.Error == nil && f.expr.test(iter, iter.data[loc:loc+length])
+	iter.pin--
+	iter.head = save
+	return ok
+}
+
+// usesRoot reports whether any filter in sel refers to the root node.
+func usesRoot(sel []selector) bool {
+	for _, s := range sel {
+		switch s := s.(type) {
+		case *filterSelector:
+			if s.root {
+				return true
+			}
+		case combineSelector:
+			if usesRoot(s) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// logicalExpr is a logical expression tested against the current node cur,
+// with the root node and options of iter, the iterator running the filter,
+// which is left where it is.
+type logicalExpr interface {
+	test(iter *Iterator, cur []byte) bool
+	appendTo(dst []byte) []byte
+}
+
+type orExpr []logicalExpr
+
+func (e orExpr) test(iter *Iterator, cur []byte) bool {
+	for _, e := range e {
+		if e.test(iter, cur) {
+			return true
+		}
+	}
+	return false
+}
+func (e orExpr) appendTo(dst []byte) []byte {
+	for i, e := range e {
+		if i != 0 {
+			dst = append(dst, " || "...)
+		}
+		dst = e.appendTo(dst)
+	}
+	return dst
+}
+
+type andExpr []logicalExpr
+
+func (e andExpr) test(iter *Iterator, cur []byte) bool {
+	for _, e := range e {
+		if !e.test(iter, cur) {
+			return false
+		}
+	}
+	return true
+}
+func (e andExpr) appendTo(dst []byte) []byte {
+	for i, e := range e {
+		if i != 0 {
+			dst = append(dst, " && "...)
+		}
+		if _, ok := e.(orExpr); ok {
+			dst = append(e.appendTo(append(dst, '(')), ')')
+		} else {
+			dst = e.appendTo(dst)
+		}
+	}
+	return dst
+}
+
+type notExpr struct{ logicalExpr }
+
+func (e notExpr) test(iter *Iterator, cur []byte) bool {
+	return !e.logicalExpr.test(iter, cur)
+}
+func (e notExpr) appendTo(dst []byte) []byte {
+	switch e.logicalExpr.(type) {
+	case existExpr, *funcExpr:
+		return e.logicalExpr.appendTo(append(dst, '!'))
+	}
+	return append(e.logicalExpr.appendTo(append(dst, '!', '(')), ')')
+}
+
+// existExpr tests whether a filter query selects any node
+type existExpr struct{ q *filterQuery }
+
+func (e existExpr) test(iter *Iterator, cur []byte) bool {
+	found := false
+	e.q.nodes(iter, cur, func([]byte) { found = true })
+	return found
+}
+func (e existExpr) appendTo(dst []byte) []byte {
+	return e.q.appendTo(dst)
+}
+
+type cmpOp uint8
+
+const (
+	cmpEq cmpOp = iota
+	cmpNe
+	cmpLt
+	cmpLe
+	cmpGt
+	cmpGe
+)
+
+var cmpOps = [...]string{cmpEq: "==", cmpNe: "!=", cmpLt: "<", cmpLe: "<=", cmpGt: ">", cmpGe: ">="}
+
+type compareExpr struct {
+	op   cmpOp
+	l, r comparable
+}
+
+func (e compareExpr) test(iter *Iterator, cur []byte) bool {
+	l, r := e.l.value(iter, cur), e.r.value(iter, cur)
+	switch e.op {
+	case cmpEq:
+		return valueEqual(l, r)
+	case cmpNe:
+		return !valueEqual(l, r)
+	case cmpLt:
+		return valueLess(l, r)
+	case cmpLe:
+		return valueLess(l, r) || valueEqual(l, r)
+	case cmpGt:
+		return valueLess(r, l)
+	default:
+		return valueLess(r, l) || valueEqual(l, r)
+	}
+}
+func (e compareExpr) appendTo(dst []byte) []byte {
+	dst = append(e.l.appendTo(dst), ' ')
+	dst = append(append(dst, cmpOps[e.op]...), ' ')
+	return e.r.appendTo(dst)
+}
+
+// comparable produces a single value, a Token with INVALID type stands for
+// Nothing, which is the result of a singular query selecting no node.
+type comparable interface {
+	value(iter *Iterator, cur []byte) Token
+	appendTo(dst []byte) []byte
+}
+
+type literal Token
+
+func (l literal) value(iter *Iterator, cur []byte) Token {
+	return Token(l)
+}
+func (l literal) appendTo(dst []byte) []byte {
+	return append(dst, l.Value...)
+}
+
+// filterQuery is a query relative to the current node (@) or the root ($)
+type filterQuery struct {
+	root bool
+	sel  []selector
+}
+
+func (q *filterQuery) nodes(iter *Iterator, cur []byte, cb func(node []byte)) {
+	var sub Iterator
+	sub.cfg = iter.cfg
+	if q.root {
+		sub.Reset(iter.root)
+	} else {
+		sub.Reset(cur)
+	}
+	sub.root = iter.root
+	traverse(&sub, q.sel, nil, func(iter *Iterator) {
+		_, loc, length := iter.Skip()
+		if iter.Error == nil {
+			cb(iter.data[loc : loc+length])
+		}
+	})
+}
+
+func (q *filterQuery) value(iter *Iterator, cur []byte) (tk Token) {
+	q.nodes(iter, cur, func(node []byte) {
+		if tk.Type == INVALID {
+			tk = Token{Type: typMap[node[0]], Value: node}
+		}
+	})
+	return
+}
+
+func (q *filterQuery) appendTo(dst []byte) []byte {
+	if q.root {
+		return appendSegments(append(dst, '$'), q.sel)
+	}
+	return appendSegments(append(dst, '@'), q.sel)
+}
+
+func (q *filterQuery) singular() bool {
+	for _, s := range q.sel {
+		switch s.(type) {
+		case nameSelector, indexSelector:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func valueEqual(l, r Token) bool {
+	if l.Type != r.Type {
+		return false
+	}
+	switch l.Type {
+	case INVALID, NULL:
+		return true
+	case BOOLEAN:
+		return bytes.Equal(l.Value, r.Value)
+	case NUMBER:
+		lf, lerr := strconv.ParseFloat(string(l.Value), 64)
+		rf, rerr := strconv.ParseFloat(string(r.Value), 64)
+		return lerr == nil && rerr == nil && lf == rf
+	case STRING:
+		ls, lok := unquoteBytes(l.Value)
+		rs, rok := unquoteBytes(r.Value)
+		return lok && rok && bytes.Equal(ls, rs)
+	case BEGIN_ARRAY:
+		le, re := arrayElems(l.Value), arrayElems(r.Value)
+		if len(le) != len(re) {
+			return false
+		}
+		for i := range le {
+			if !valueEqual(le[i], re[i]) {
+				return false
+			}
+		}
+		return true
+	case BEGIN_OBJECT:
+		lk, lv := objectMembers(l.Value)
+		rk, rv := objectMembers(r.Value)
+		if len(lk) != len(rk) {
+			return false
+		}
+	members:
+		for i := range lk {
+			for j := range rk {
+				if valueEqual(lk[i], rk[j]) {
+					if !valueEqual(lv[i], rv[j]) {
+						return false
+					}
+					continue members
+				}
+			}
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+func valueLess(l, r Token) bool {
+	switch {
+	case l.Type == NUMBER && r.Type == NUMBER:
+		lf, lerr := strconv.ParseFloat(string(l.Value), 64)
+		rf, rerr := strconv.ParseFloat(string(r.Value), 64)
+		return lerr == nil && rerr == nil && lf < rf
+	case l.Type == STRING && r.Type == STRING:
+		// byte order of utf-8 is the same as the order of code points
+		ls, _ := unquoteBytes(l.Value)
+		rs, _ := unquoteBytes(r.Value)
+		return bytes.Compare(ls, rs) < 0
+	}
+	return false
+}
+
+// arrayElems returns the elements of the array raw, which has been read
+// already, so it's read again without limits.
+func arrayElems(raw []byte) (elems []Token) {
+	var iter Iterator
+	iter.cfg.limits.MaxDepth = -1
+	iter.Reset(raw)
+	iter.NextArray(func(idx int) bool {
+		typ, loc, length := iter.Skip()
+		elems = append(elems, Token{Type: typ, Value: raw[loc : loc+length]})
+		return true
+	})
+	return
+}
+
+// objectMembers returns the keys and values of the members of the object
+// raw, which has been read already, so it's read again without limits.
+func objectMembers(raw []byte) (keys, vals []Token) {
+	var iter Iterator
+	iter.cfg.limits.MaxDepth = -1
+	iter.Reset(raw)
+	iter.NextObject(func(key *Token) bool {
+		keys = append(keys, Token{Type: STRING, Value: key.Value})
+		typ, loc, length := iter.Skip()
+		vals = append(vals, Token{Type: typ, Value: raw[loc : loc+length]})
+		return true
+	})
+	return
+}
+
+// filterParser parses logical expressions in filter selectors
+type filterParser struct {
+	s    string
+	pos  int
+	root bool
+}
+
+// parseFilter parses the logical expression following "?" in b, returning
+// the number of bytes consumed.
+func parseFilter(b string) (int, *filterSelector, error) {
+	p := filterParser{s: b}
+	expr, err := p.parseOr()
+	if err != nil {
+		return p.pos, nil, err
+	}
+	return p.pos, &filterSelector{expr: expr, root: p.root}, nil
+}
+
+func (p *filterParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s in filter at %d", ErrInvalidJsonpath, fmt.Sprintf(format, args...), p.pos)
+}
+
+func (p *filterParser) ws() {
+	for p.pos < len(p.s) && emptyChar.c(p.s[p.pos]) {
+		p.pos++
+	}
+}
+
+// consume consumes tok after optional blank spaces
+func (p *filterParser) consume(tok string) bool {
+	save := p.pos
+	p.ws()
+	if len(p.s)-p.pos >= len(tok) && p.s[p.pos:p.pos+len(tok)] == tok {
+		p.pos += len(tok)
+		return true
+	}
+	p.pos = save
+	return false
+}
+
+func (p *filterParser) parseOr() (logicalExpr, error) {
+	var or orExpr
+	for {
+		e, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		or = append(or, e)
+		if !p.consume("||") {
+			break
+		}
+	}
+	if len(or) == 1 {
+		return or[0], nil
+	}
+	return or, nil
+}
+
+func (p *filterParser) parseAnd() (logicalExpr, error) {
+	var and andExpr
+	for {
+		e, err := p.parseBasic()
+		if err != nil {
+			return nil, err
+		}
+		and = append(and, e)
+		if !p.consume("&&") {
+			break
+		}
+	}
+	if len(and) == 1 {
+		return and[0], nil
+	}
+	return and, nil
+}
+
+func (p *filterParser) parseBasic() (logicalExpr, error) {
+	p.ws()
+	if p.consume("!") {
+		p.ws()
+		var e logicalExpr
+		var err error
+		if p.consume("(") {
+			e, err = p.parseParen()
+		} else {
+			e, err = p.parseTest()
+		}
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{e}, nil
+	}
+	if p.consume("(") {
+		return p.parseParen()
+	}
+	start := p.pos
+	l, err := p.parseComparable()
+	if err != nil {
+		return nil, err
+	}
+	lsrc := p.s[start:p.pos]
+	op, ok := p.parseCmpOp()
+	if !ok {
+		return testExpr(l, lsrc)
+	}
+	if err := checkComparable(l, lsrc); err != nil {
+		return nil, err
+	}
+	p.ws()
+	start = p.pos
+	r, err := p.parseComparable()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkComparable(r, p.s[start:p.pos]); err != nil {
+		return nil, err
+	}
+	return compareExpr{op: op, l: l, r: r}, nil
+}
+
+func (p *filterParser) parseParen() (logicalExpr, error) {
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.consume(")") {
+		return nil, p.errorf("expected )")
+	}
+	return e, nil
+}
+
+// parseTest parses a test expression, which must be a filter query or a
+// function expression
+func (p *filterParser) parseTest() (logicalExpr, error) {
+	start := p.pos
+	l, err := p.parseComparable()
+	if err != nil {
+		return nil, err
+	}
+	return testExpr(l, p.s[start:p.pos])
+}
+
+func testExpr(c comparable, src string) (logicalExpr, error) {
+	switch c := c.(type) {
+	case *filterQuery:
+		return existExpr{c}, nil
+	case *funcExpr:
+		if c.fn.Result != ValueType {
+			return c, nil
+		}
+		return nil, fmt.Errorf("%w: %q of ValueType can't be tested in filter", ErrInvalidJsonpath, src)
+	}
+	return nil, fmt.Errorf("%w: expected comparison after %q in filter", ErrInvalidJsonpath, src)
+}
+
+func checkComparable(c comparable, src string) error {
+	switch c := c.(type) {
+	case *filterQuery:
+		if !c.singular() {
+			return fmt.Errorf("%w: non-singular query %q compared in filter", ErrInvalidJsonpath, src)
+		}
+	case *funcExpr:
+		if c.fn.Result != ValueType {
+			return fmt.Errorf("%w: %q of %s compared in filter", ErrInvalidJsonpath, src, c.fn.Result)
+		}
+	}
+	return nil
+}
+
+func (p *filterParser) parseCmpOp() (cmpOp, bool) {
+	p.ws()
+	for _, op := range []cmpOp{cmpEq, cmpNe, cmpLe, cmpGe, cmpLt, cmpGt} {
+		if p.consume(cmpOps[op]) {
+			return op, true
+		}
+	}
+	return 0, false
+}
+
+func (p *filterParser) parseComparable() (comparable, error) {
+	p.ws()
+	if p.pos >= len(p.s) {
+		return nil, p.errorf("unexpected end")
+	}
+	switch c := p.s[p.pos]; {
+	case c == '@' || c == '$':
+		sel, rest, err := parseSegments(p.s[p.pos+1:])
+		if err != nil {
+			return nil, err
+		}
+		p.pos = len(p.s) - len(rest)
+		p.root = p.root || c == '$' || usesRoot(sel)
+		return &filterQuery{root: c == '$', sel: sel}, nil
+	case c == '\'' || c == '"':
+		end, s, err := parseStringLiteral(p.s[p.pos:])
+		if err != nil {
+			return nil, err
+		}
+		p.pos += end
+		return literal{Type: STRING, Value: appendQuoted(nil, s)}, nil
+	case c == '-' || c >= '0' && c <= '9':
+		end := scanNumberLiteral(p.s[p.pos:])
+		if end == 0 {
+			return nil, p.errorf("invalid number")
+		}
+		lit := literal{Type: NUMBER, Value: []byte(p.s[p.pos : p.pos+end])}
+		p.pos += end
+		return lit, nil
+	}
+	n := scanFuncName(p.s[p.pos:])
+	if p.pos+n < len(p.s) && p.s[p.pos+n] == '(' {
+		return p.parseFunc()
+	}
+	switch kw := p.s[p.pos : p.pos+n]; kw {
+	case "true", "false":
+		p.pos += n
+		return literal{Type: BOOLEAN, Value: []byte(kw)}, nil
+	case "null":
+		p.pos += n
+		return literal{Type: NULL, Value: []byte(kw)}, nil
+	}
+	return nil, p.errorf("unexpected %q", p.s[p.pos])
+}
+
+// scanNumberLiteral returns the length of the number at the start of s,
+// or 0 if it's not a valid number according to RFC 9535
+func scanNumberLiteral(s string) int {
+	i := 0
+	if i < len(s) && s[i] == '-' {
+		i++
+	}
+	digits := func() int {
+		j := i
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+		return i - j
+	}
+	if i < len(s) && s[i] == '0' {
+		i++
+	} else if digits() == 0 {
+		return 0
+	}
+	if i < len(s) && s[i] == '.' {
+		i++
+		if digits() == 0 {
+			return 0
+		}
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		i++
+		if i < len(s) && (s[i] == '+' || s[i] == '-') {
+			i++
+		}
+		if digits() == 0 {
+			return 0
+		}
+	}
+	return i
+}
+
+// parseStringLiteral parses the single or double quoted string literal at the
+// start of b, returning its length and unescaped content.
+func parseStringLiteral(b string) (end int, s string, err error) {
+	q := b[0]
+	var out []byte
+	for end = 1; end < len(b); {
+		c := b[end]
+		switch {
+		case c == q:
+			return end + 1, string(out), nil
+		case c < 0x20:
+			return end, "", fmt.Errorf("%w: control character in string literal", ErrInvalidJsonpath)
+		case c != '\\':
+			out = append(out, c)
+			end++
+			continue
+		}
+		if end+1 >= len(b) {
+			break
+		}
+		switch c = b[end+1]; c {
+		case 'b', 'f', 'n', 'r', 't', '/', '\\':
+			out = append(out, escapeChars[c])
+			end += 2
+		case q:
+			out = append(out, q)
+			end += 2
+		case 'u':
+			r, n := parseHexEscape(b[end:])
+			if n == 0 {
+				return end, "", fmt.Errorf("%w: invalid unicode escape in string literal", ErrInvalidJsonpath)
+			}
+			out = utf8.AppendRune(out, r)
+			end += n
+		default:
+			return end, "", fmt.Errorf("%w: invalid escape in string literal", ErrInvalidJsonpath)
+		}
+	}
+	return end, "", fmt.Errorf("%w: unterminated string literal", ErrInvalidJsonpath)
+}
+
+// parseHexEscape parses \uXXXX or a surrogate pair \uXXXX\uXXXX, returning
+// the rune and the length consumed, or 0 if the escape is invalid.
+func parseHexEscape(b string) (rune, int) {
+	hex4 := func(s string) rune {
+		if len(s) < 6 || s[0] != '\\' || s[1] != 'u' {
+			return -1
+		}
+		var r rune
+		for i := 2; i < 6; i++ {
+			if u4map[s[i]] < 0 {
+				return -1
+			}
+			r = r<<4 | u4map[s[i]]
+		}
+		return r
+	}
+	r := hex4(b)
+	switch {
+	case r < 0 || r >= 0xDC00 && r <= 0xDFFF:
+		return 0, 0
+	case r >= 0xD800 && r <= 0xDBFF:
+		r2 := hex4(b[6:])
+		if r2 < 0xDC00 || r2 > 0xDFFF {
+			return 0, 0
+		}
+		return utf16.DecodeRune(r, r2), 12
+	}
+	return r, 6
+}
+
+// appendQuoted appends s as a JSON string
+func appendQuoted(dst []byte, s string) []byte {
+	const hex = "0123456789abcdef"
+	dst = append(dst, '"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' || c == '\\':
+			dst = append(dst, '\\', c)
+		case c < 0x20:
+			dst = append(dst, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xf])
+		default:
+			dst = append(dst, c)
+		}
+	}
+	return append(dst, '"')
+}
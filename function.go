@@ -0,0 +1,334 @@
+package jsontk
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// FuncType is the declared type of parameters and results of function
+// extensions, see section 2.4.1 of RFC 9535.
+type FuncType uint8
+
+const (
+	ValueType FuncType = iota
+	LogicalType
+	NodesType
+)
+
+var nameOfFuncType = [...]string{ValueType: "ValueType", LogicalType: "LogicalType", NodesType: "NodesType"}
+
+func (t FuncType) String() string {
+	if int(t) < len(nameOfFuncType) {
+		return nameOfFuncType[t]
+	}
+	return "FuncType(" + strconv.Itoa(int(t)) + ")"
+}
+
+// FuncValue is an argument or result of a function extension, the field in
+// use is decided by its declared type.
+type FuncValue struct {
+	Value   Token   // ValueType, a Token with INVALID type stands for Nothing
+	Logical bool    // LogicalType
+	Nodes   []Token // NodesType
+}
+
+// Func is a function extension usable in filter expressions. Values of
+// objects and arrays are passed as Tokens with BEGIN_OBJECT or BEGIN_ARRAY
+// type and the raw json as their Value.
+type Func struct {
+	Params []FuncType
+	Result FuncType
+	Call   func(args []FuncValue) FuncValue
+}
+
+var funcs sync.Map // name to Func, or to iregexpFunc for match and search
+
+func init() {
+	funcs.Store("length", Func{[]FuncType{ValueType}, ValueType, fnLength})
+	funcs.Store("count", Func{[]FuncType{NodesType}, ValueType, fnCount})
+	funcs.Store("match", iregexpFunc(true))
+	funcs.Store("search", iregexpFunc(false))
+	funcs.Store("value", Func{[]FuncType{NodesType}, ValueType, fnValue})
+}
+
+// RegisterFunc registers a function extension, replacing any previously
+// registered one with the same name. Paths already parsed are not affected.
+func RegisterFunc(name string, fn Func) error {
+	if scanFuncName(name) != len(name) {
+		return fmt.Errorf("%w: invalid function name %q", ErrInvalidJsonpath, name)
+	}
+	if fn.Call == nil || fn.Result > NodesType {
+		return fmt.Errorf("%w: invalid function %q", ErrInvalidJsonpath, name)
+	}
+	for _, p := range fn.Params {
+		if p > NodesType {
+			return fmt.Errorf("%w: invalid function %q", ErrInvalidJsonpath, name)
+		}
+	}
+	funcs.Store(name, fn)
+	return nil
+}
+
+// scanFuncName returns the length of the function name at the start of s
+func scanFuncName(s string) int {
+	if len(s) == 0 || s[0] < 'a' || s[0] > 'z' {
+		return 0
+	}
+	i := 1
+	for i < len(s) && (s[i] >= 'a' && s[i] <= 'z' || s[i] >= '0' && s[i] <= '9' || s[i] == '_') {
+		i++
+	}
+	return i
+}
+
+func numberToken(n int) Token {
+	return Token{Type: NUMBER, Value: strconv.AppendInt(nil, int64(n), 10)}
+}
+
+func fnLength(args []FuncValue) FuncValue {
+	v := args[0].Value
+	switch v.Type {
+	case STRING:
+		s, _ := unquoteBytes(v.Value)
+		return FuncValue{Value: numberToken(utf8.RuneCount(s))}
+	case BEGIN_ARRAY:
+		return FuncValue{Value: numberToken(len(arrayElems(v.Value)))}
+	case BEGIN_OBJECT:
+		keys, _ := objectMembers(v.Value)
+		return FuncValue{Value: numberToken(len(keys))}
+	}
+	return FuncValue{}
+}
+
+func fnCount(args []FuncValue) FuncValue {
+	return FuncValue{Value: numberToken(len(args[0].Nodes))}
+}
+
+func fnValue(args []FuncValue) FuncValue {
+	if len(args[0].Nodes) != 1 {
+		return FuncValue{}
+	}
+	return FuncValue{Value: args[0].Nodes[0]}
+}
+
+// iregexpFunc is the match function if it's true, or search otherwise.
+type iregexpFunc bool
+
+// fn returns the function matching against pattern if it's a literal, which
+// is compiled once here, or against the pattern it's called with otherwise,
+// which may come from the document and is compiled on each call.
+func (full iregexpFunc) fn(pattern interface{}) Func {
+	call := func(args []FuncValue) FuncValue {
+		return FuncValue{Logical: iregexpMatch(args[0].Value, compilePattern(args[1].Value, bool(full)))}
+	}
+	if lit, ok := pattern.(literal); ok {
+		re := compilePattern(Token(lit), bool(full))
+		call = func(args []FuncValue) FuncValue {
+			return FuncValue{Logical: iregexpMatch(args[0].Value, re)}
+		}
+	}
+	return Func{[]FuncType{ValueType, ValueType}, LogicalType, call}
+}
+
+// compilePattern compiles the I-Regexp (RFC 9485) pattern, nil if it's not
+// a valid one.
+func compilePattern(pattern Token, full bool) *regexp.Regexp {
+	if pattern.Type != STRING {
+		return nil
+	}
+	pat, ok := unquoteBytes(pattern.Value)
+	if !ok {
+		return nil
+	}
+	return compileIRegexp(string(pat), full)
+}
+
+// iregexpMatch matches s against the compiled pattern re.
+func iregexpMatch(s Token, re *regexp.Regexp) bool {
+	if s.Type != STRING || re == nil {
+		return false
+	}
+	str, ok := unquote(s.Value)
+	return ok && re.MatchString(str)
+}
+
+// compileIRegexp maps an I-Regexp onto go regexp, as described in section 5.3
+// of RFC 9485. It returns nil if the pattern is invalid.
+func compileIRegexp(pattern string, full bool) *regexp.Regexp {
+	var sb strings.Builder
+	if full {
+		sb.WriteString(`\A(?:`)
+	}
+	inClass := false
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case c == '\\' && i+1 < len(pattern):
+			sb.WriteByte(c)
+			i++
+			c = pattern[i]
+			sb.WriteByte(c)
+		case c == '[':
+			inClass = true
+			sb.WriteByte(c)
+		case c == ']':
+			inClass = false
+			sb.WriteByte(c)
+		case c == '.' && !inClass:
+			// "." doesn't match line terminators in I-Regexp
+			sb.WriteString(`[^\n\r]`)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	if full {
+		sb.WriteString(`)\z`)
+	}
+	re, err := regexp.Compile(sb.String())
+	if err != nil {
+		return nil
+	}
+	return re
+}
+
+// funcExpr is a function call in filter expressions. Its arguments are
+// well-typed against the declared parameter types while parsing.
+type funcExpr struct {
+	name string
+	fn   Func
+	args []interface{} // literal, *filterQuery, logicalExpr or *funcExpr
+}
+
+func (f *funcExpr) call(iter *Iterator, cur []byte) FuncValue {
+	args := make([]FuncValue, len(f.args))
+	for i, arg := range f.args {
+		switch param := f.fn.Params[i]; arg := arg.(type) {
+		case literal:
+			args[i].Value = Token(arg)
+		case *funcExpr:
+			res := arg.call(iter, cur)
+			if param == LogicalType && arg.fn.Result == NodesType {
+				res.Logical = len(res.Nodes) != 0
+			}
+			args[i] = res
+		case *filterQuery:
+			switch param {
+			case ValueType:
+				args[i].Value = arg.value(iter, cur)
+			case LogicalType:
+				args[i].Logical = existExpr{arg}.test(iter, cur)
+			case NodesType:
+				arg.nodes(iter, cur, func(node []byte) {
+					args[i].Nodes = append(args[i].Nodes, Token{Type: typMap[node[0]], Value: node})
+				})
+			}
+		case logicalExpr:
+			args[i].Logical = arg.test(iter, cur)
+		}
+	}
+	return f.fn.Call(args)
+}
+
+func (f *funcExpr) value(iter *Iterator, cur []byte) Token {
+	return f.call(iter, cur).Value
+}
+
+func (f *funcExpr) test(iter *Iterator, cur []byte) bool {
+	res := f.call(iter, cur)
+	if f.fn.Result == NodesType {
+		return len(res.Nodes) != 0
+	}
+	return res.Logical
+}
+
+func (f *funcExpr) appendTo(dst []byte) []byte {
+	dst = append(append(dst, f.name...), '(')
+	for i, arg := range f.args {
+		if i != 0 {
+			dst = append(dst, ',', ' ')
+		}
+		dst = arg.(interface{ appendTo([]byte) []byte }).appendTo(dst)
+	}
+	return append(dst, ')')
+}
+
+// parseFunc parses a function expression starting with its name
+func (p *filterParser) parseFunc() (*funcExpr, error) {
+	n := scanFuncName(p.s[p.pos:])
+	name := p.s[p.pos : p.pos+n]
+	v, ok := funcs.Load(name)
+	if !ok {
+		return nil, p.errorf("unknown function %s", name)
+	}
+	re, isRe := v.(iregexpFunc)
+	if isRe {
+		v = re.fn(nil)
+	}
+	f := &funcExpr{name: name, fn: v.(Func)}
+	p.pos += n + 1 // name and "("
+	for i := 0; ; i++ {
+		if i == 0 && p.consume(")") {
+			break
+		}
+		if i >= len(f.fn.Params) {
+			return nil, p.errorf("too many arguments for %s", name)
+		}
+		arg, err := p.parseFuncArg(f.fn.Params[i])
+		if err != nil {
+			return nil, err
+		}
+		f.args = append(f.args, arg)
+		if p.consume(")") {
+			break
+		}
+		if !p.consume(",") {
+			return nil, p.errorf("expected , or ) in arguments of %s", name)
+		}
+	}
+	if len(f.args) != len(f.fn.Params) {
+		return nil, p.errorf("%s expects %d arguments, got %d", name, len(f.fn.Params), len(f.args))
+	}
+	if isRe {
+		f.fn = re.fn(f.args[1])
+	}
+	return f, nil
+}
+
+// parseFuncArg parses a function argument and checks that it's well-typed
+// according to section 2.4.3 of RFC 9535.
+func (p *filterParser) parseFuncArg(param FuncType) (interface{}, error) {
+	p.ws()
+	start := p.pos
+	var arg interface{}
+	c, err := p.parseComparable()
+	if save := p.pos; err == nil && (p.consume(",") || p.consume(")")) {
+		p.pos, arg = save, c
+	} else {
+		p.pos = start
+		if arg, err = p.parseOr(); err != nil {
+			return nil, err
+		}
+	}
+	switch arg := arg.(type) {
+	case literal:
+		if param == ValueType {
+			return arg, nil
+		}
+	case *filterQuery:
+		if param != ValueType || arg.singular() {
+			return arg, nil
+		}
+	case *funcExpr:
+		if arg.fn.Result == param || param == LogicalType && arg.fn.Result == NodesType {
+			return arg, nil
+		}
+	case logicalExpr:
+		if param == LogicalType {
+			return arg, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: argument %q is not of %s", ErrInvalidJsonpath, p.s[start:p.pos], param)
+}
@@ -0,0 +1,177 @@
+package jsontk
+
+import "sort"
+
+// Index is a structural index of a json document, also known as a tape: the
+// type and location of each token, in the order [Iterate] reads them, with
+// the matching end of each object and array. It lets queries on the same
+// document jump over values instead of reading them again, see
+// [Iterator.ResetIndex] and [Index.Lookup]. It's immutable and safe for
+// concurrent use.
+type Index struct {
+	data []byte
+	tape []indexEntry
+}
+
+// indexEntry is a token in the tape.
+type indexEntry struct {
+	typ      TokenType
+	off, len int
+	end      int // tape position of the matching end of objects and arrays
+}
+
+// BuildIndex reads data, which must be a single json value, in one pass and
+// indexes its tokens. Tokens are checked with the options as in [Iterate].
+func BuildIndex(data []byte, opts ...Option) (*Index, error) {
+	x := &Index{data: data, tape: make([]indexEntry, 0, len(data)/8)}
+	var stack []int      // tape positions of the containers being read
+	var bad *SyntaxError // Iterate doesn't check structure, nor stop on its own
+	err := Iterate(data, func(typ TokenType, idx, length int) {
+		if bad != nil || typ == INVALID {
+			return
+		}
+		n := len(x.tape)
+		afterKey := n > 0 && x.tape[n-1].typ == KEY
+		inObject := len(stack) > 0 && x.tape[stack[len(stack)-1]].typ == BEGIN_OBJECT
+		switch {
+		case n > 0 && len(stack) == 0:
+			bad = newSyntaxError(ErrUnexpectedToken, idx, "expected EOF")
+		case afterKey && (typ == KEY || typ == END_OBJECT || typ == END_ARRAY):
+			bad = newSyntaxError(ErrUnexpectedToken, idx, "expected value")
+		case typ == END_OBJECT || typ == END_ARRAY:
+			// END_OBJECT and END_ARRAY come right after their beginnings
+			if len(stack) == 0 || x.tape[stack[len(stack)-1]].typ+1 != typ {
+				bad = newSyntaxError(ErrInvalidParentheses, idx, "unexpected "+typ.String())
+				return
+			}
+			// Iterate accepts [,] and {,} unless strict
+			if begin := stack[len(stack)-1]; begin == n-1 {
+				if i := skip(data, x.tape[begin].off+1); i < idx {
+					bad = newSyntaxError(ErrUnexpectedSep, i, "unexpected comma")
+					return
+				}
+			}
+			x.tape[stack[len(stack)-1]].end = n
+			stack = stack[:len(stack)-1]
+		case inObject && !afterKey && typ != KEY:
+			bad = newSyntaxError(ErrUnexpectedToken, idx, "expected string key", KEY, END_OBJECT)
+		case !inObject && typ == KEY:
+			bad = newSyntaxError(ErrUnexpectedToken, idx, "unexpected object key")
+		case typ == BEGIN_OBJECT || typ == BEGIN_ARRAY:
+			stack = append(stack, n)
+		}
+		x.tape = append(x.tape, indexEntry{typ: typ, off: idx, len: length, end: n})
+	}, opts...)
+	switch {
+	case err != nil:
+		return nil, err
+	case bad != nil:
+		return nil, iterateError(data, bad)
+	case len(x.tape) == 0:
+		return nil, iterateError(data, newSyntaxError(ErrEarlyEOF, len(data), ""))
+	case len(stack) > 0:
+		end := x.tape[stack[len(stack)-1]].typ + 1
+		return nil, iterateError(data, newSyntaxError(ErrEarlyEOF, len(data), "expected "+end.String(), end))
+	}
+	// Iterate accepts a comma after the value, unless strict
+	if i := skip(data, x.tape[0].off+x.span(0)); i < len(data) {
+		return nil, iterateError(data, newSyntaxError(ErrUnexpectedToken, i, "expected EOF"))
+	}
+	return x, nil
+}
+
+// find returns the tape position of the token at offset off, or -1 if no
+// token starts there. The search starts at *hint, which is moved past the
+// value there, so that reading the document forward takes amortized
+// constant time per token.
+func (x *Index) find(off int, hint *int) int {
+	h := *hint
+	if h >= len(x.tape) || x.tape[h].off > off {
+		h = sort.Search(len(x.tape), func(i int) bool { return x.tape[i].off >= off })
+	}
+	for h < len(x.tape) && x.tape[h].off < off {
+		h++
+	}
+	if h == len(x.tape) || x.tape[h].off != off {
+		return -1
+	}
+	*hint = x.tape[h].end + 1
+	return h
+}
+
+// span returns the length of the value at tape position p.
+func (x *Index) span(p int) int {
+	if end := x.tape[p].end; end != p {
+		return x.tape[end].off + 1 - x.tape[p].off
+	}
+	return x.tape[p].len
+}
+
+// Lookup returns the type, location and length of the value referenced by
+// the JSON Pointer, as [Iterator.Skip] would, jumping over the members
+// before it.
+func (x *Index) Lookup(ptr string) (TokenType, int, int, error) {
+	p, err := CompilePointer(ptr)
+	if err != nil {
+		return INVALID, 0, 0, err
+	}
+	return x.LookupCompiled(p)
+}
+
+// LookupCompiled is like [Index.Lookup] but takes a compiled pointer.
+func (x *Index) LookupCompiled(p *Pointer) (TokenType, int, int, error) {
+	pos := 0
+	for _, sel := range p.sel {
+		if pos = x.member(pos, sel.(tokenSelector)); pos < 0 {
+			return INVALID, 0, 0, ErrPathNotFound
+		}
+	}
+	e := &x.tape[pos]
+	return e.typ, e.off, x.span(pos), nil
+}
+
+// member returns the tape position of the member of the container at tape
+// position pos referenced by s, or -1 if there's none.
+func (x *Index) member(pos int, s tokenSelector) int {
+	switch x.tape[pos].typ {
+	case BEGIN_OBJECT:
+		for p := pos + 1; x.tape[p].typ == KEY; p = x.tape[p+1].end + 1 {
+			if key := x.tape[p]; unquotedEqualStr(x.data[key.off:key.off+key.len], string(s.name)) {
+				return p + 1
+			}
+		}
+	case BEGIN_ARRAY:
+		if s.index < 0 {
+			return -1
+		}
+		p := pos + 1
+		for i := 0; i < int(s.index) && p < x.tape[pos].end; i++ {
+			p = x.tape[p].end + 1
+		}
+		if p < x.tape[pos].end {
+			return p
+		}
+	}
+	return -1
+}
+
+// ResetIndex makes the iterator read the indexed document, in which
+// [Iterator.Skip], and so [Iterator.Select] for the values not selected,
+// jumps over objects and arrays without reading them again. Their contents
+// were checked when the index was built, with the options given to
+// [BuildIndex] rather than the options of the iterator.
+func (iter *Iterator) ResetIndex(x *Index) {
+	iter.Reset(x.data)
+	iter.index, iter.hint = x, 0
+}
+
+// skipIndexed skips the object or array at head with the index, reporting
+// whether it's indexed.
+func (iter *Iterator) skipIndexed() bool {
+	p := iter.index.find(iter.head, &iter.hint)
+	if p < 0 {
+		return false
+	}
+	iter.head = iter.index.tape[iter.index.tape[p].end].off + 1
+	return true
+}
@@ -0,0 +1,110 @@
+package jsontk
+
+import (
+	"errors"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestBuildIndex(t *testing.T) {
+	x, err := BuildIndex([]byte(` {"a": [1, {"b": []}], "c": "d"} `))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(x.tape) != 13 || x.tape[0].end != 12 || x.tape[2].end != 9 || x.tape[4].end != 8 || x.span(2) != 14 {
+		t.Errorf("unexpected tape %+v", x.tape)
+	}
+
+	for data, want := range map[string]error{
+		``:                ErrEarlyEOF,
+		`[1, [2]`:         ErrEarlyEOF,
+		`[1}`:             ErrInvalidParentheses,
+		`{"a": 1]`:        ErrInvalidParentheses,
+		`{"a"}`:           ErrUnexpectedToken,
+		`{"a": }`:         ErrUnexpectedToken,
+		`{"a": "b": 1}`:   ErrUnexpectedToken,
+		`["a": 1]`:        ErrUnexpectedToken,
+		`[,]`:             ErrUnexpectedSep,
+		`{"a": { , }}`:    ErrUnexpectedSep,
+		`[1] [2]`:         ErrUnexpectedSep,
+		`[1],`:            ErrUnexpectedToken,
+		`{"a": [1, tru]}`: ErrUnexpectedToken,
+	} {
+		if _, err := BuildIndex([]byte(data)); !errors.Is(err, want) {
+			t.Errorf("%s: expected %v, got %v", data, want, err)
+		}
+	}
+	if _, err := BuildIndex([]byte(`[1, 2,]`), Strict()); !errors.Is(err, ErrUnexpectedSep) {
+		t.Errorf("expected trailing comma to be rejected, got %v", err)
+	}
+}
+
+func TestIndexSelect(t *testing.T) {
+	for file, paths := range map[string][]string{
+		"twitter.json":      {"$.statuses[*].id", "$.statuses[3].user.name", "$..hashtags[*].text", "$.statuses[?@.retweet_count > 0].id_str", "$.search_metadata"},
+		"citm_catalog.json": {"$.events['138586341'].name", "$.performances[-1].seatCategories[0]", "$.topicNames['324846100']", "$..areaId"},
+	} {
+		data, _ := os.ReadFile(path.Join("./testdata", file))
+		x, err := BuildIndex(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var indexed, plain Iterator
+		for _, p := range paths {
+			var got, want []string
+			indexed.ResetIndex(x)
+			if err := indexed.Select(p, func(iter *Iterator) {
+				_, loc, length := iter.Skip()
+				got = append(got, string(iter.data[loc:loc+length]))
+			}); err != nil {
+				t.Fatal(err)
+			}
+			plain.Reset(data)
+			plain.Select(p, func(iter *Iterator) {
+				_, loc, length := iter.Skip()
+				want = append(want, string(iter.data[loc:loc+length]))
+			})
+			if len(got) == 0 || len(got) != len(want) {
+				t.Fatalf("%s %s: selected %d values, want %d", file, p, len(got), len(want))
+			}
+			for i := range got {
+				if got[i] != want[i] {
+					t.Errorf("%s %s: selected %.32s, want %.32s", file, p, got[i], want[i])
+				}
+			}
+		}
+	}
+}
+
+func TestIndexLookup(t *testing.T) {
+	data := []byte(`{"a": [1, {"b/c": [true]}, "x"], "d": {}, "": 2}`)
+	x, err := BuildIndex(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for ptr, want := range map[string]string{
+		"":          string(data),
+		"/a":        `[1, {"b/c": [true]}, "x"]`,
+		"/a/1/b~1c": `[true]`,
+		"/a/2":      `"x"`,
+		"/d":        `{}`,
+		"/":         `2`,
+		"/a/3":      "",
+		"/a/-":      "",
+		"/a/01":     "",
+		"/d/a":      "",
+		"/a/0/b":    "",
+	} {
+		typ, loc, length, err := x.Lookup(ptr)
+		if want == "" {
+			if !errors.Is(err, ErrPathNotFound) {
+				t.Errorf("%s: expected ErrPathNotFound, got %v", ptr, err)
+			}
+			continue
+		}
+		if err != nil || string(data[loc:loc+length]) != want || typ != typMap[want[0]] {
+			t.Errorf("%s: got %v %s %v, want %s", ptr, typ, data[loc:loc+length], err, want)
+		}
+	}
+}
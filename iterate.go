@@ -1,48 +1,38 @@
 package jsontk
 
 import (
-	"fmt"
+	"unicode/utf16"
+	"unicode/utf8"
 )
 
 func skip(s []byte, i int) int {
-	for i < len(s) {
-		switch s[i] {
-		case ' ', '\n', '\t', '\r':
-			i++
-		default:
-			return i
-		}
+	if i >= len(s) || s[i] > ' ' { // tokens are mostly not preceded by whitespace
+		return i
 	}
-	return i
+	return i + skipSpaceSWAR(s[i:])
 }
 
+// eos returns the location of the quote ending the string containing i, or
+// len(s) if it's unterminated.
 func eos(s []byte, i int) int {
-	isEscaped := false
-	for ; i < len(s) && (s[i] != '"' || isEscaped); i++ {
-		if s[i] == '\\' && !isEscaped {
-			isEscaped = true
-		} else {
-			isEscaped = false
+	for i < len(s) {
+		if i += indexQuote(s[i:]); i < len(s) && s[i] == '"' {
+			return i
 		}
+		i += 2 // the backslash and the byte escaped
 	}
-	return i
+	return len(s)
 }
 
 func next(s []byte, i int) (typ TokenType, length int, err error) {
 	if len(s) <= i {
-		return INVALID, 0, ErrEarlyEOF
+		return INVALID, 0, newSyntaxError(ErrEarlyEOF, i, "")
 	}
 	switch s[i] {
 	case '"':
-		j := i + 1
-		for ; j < len(s) && s[j] != '"'; j++ {
-			if s[j] == '\\' {
-				j = eos(s, j)
-				break
-			}
-		}
+		j := eos(s, i+1)
 		if j == len(s) {
-			return INVALID, 0, fmt.Errorf("%w, expected end of string", ErrEarlyEOF)
+			return INVALID, 0, newSyntaxError(ErrEarlyEOF, i, "expected end of string")
 		}
 		return STRING, j - i + 1, nil
 	case '{':
@@ -63,17 +53,17 @@ func next(s []byte, i int) (typ TokenType, length int, err error) {
 		return NUMBER, j - i, nil
 	case 't':
 		if len(s)-i < 4 || string(s[i:i+4]) != "true" {
-			return INVALID, 0, fmt.Errorf("%w, expected boolean", ErrUnexpectedToken)
+			return INVALID, 0, newSyntaxError(ErrUnexpectedToken, i, "expected boolean", BOOLEAN)
 		}
 		return BOOLEAN, 4, nil
 	case 'f':
 		if len(s)-i < 5 || string(s[i+1:i+5]) != "alse" {
-			return INVALID, 0, fmt.Errorf("%w, expected boolean", ErrUnexpectedToken)
+			return INVALID, 0, newSyntaxError(ErrUnexpectedToken, i, "expected boolean", BOOLEAN)
 		}
 		return BOOLEAN, 5, nil
 	case 'n':
 		if len(s)-i < 4 || string(s[i:i+4]) != "null" {
-			return INVALID, 0, fmt.Errorf("%w, expected null", ErrUnexpectedToken)
+			return INVALID, 0, newSyntaxError(ErrUnexpectedToken, i, "expected null", NULL)
 		}
 		return NULL, 4, nil
 	case '}':
@@ -81,17 +71,155 @@ func next(s []byte, i int) (typ TokenType, length int, err error) {
 	case ']':
 		return END_ARRAY, 1, nil
 	default:
-		return INVALID, 0, ErrUnexpectedToken
+		return INVALID, 0, newSyntaxError(ErrUnexpectedToken, i, "")
+	}
+}
+
+// plainChars are the bytes which may appear in strings as is, regardless of
+// options.
+var plainChars = func() (t [256]bool) {
+	for c := 0x20; c < utf8.RuneSelf; c++ {
+		t[c] = c != '"' && c != '\\'
+	}
+	return
+}()
+
+// checkString returns the offset of the first control character or invalid
+// escape sequence in the quoted string s if strict, or of the first invalid
+// UTF-8 sequence or escaped lone surrogate if validUTF8, together with what's
+// wrong. The offset is -1 if s is fine.
+func checkString(s []byte, strict, validUTF8 bool) (int, string) {
+	_, bad, msg := scanString(s, 0, strict, validUTF8)
+	return bad, msg
+}
+
+// scanString scans the string starting with the quote at i, and returns the
+// location right after it. It stops at violations as described in
+// checkString, returning -1 and the location of the violation, which is -1
+// if there's none, e.g. when the string is unterminated.
+func scanString(s []byte, i int, strict, validUTF8 bool) (end, bad int, msg string) {
+	for i++; i < len(s); {
+		if plainChars[s[i]] {
+			i += indexSpecial(s[i:])
+			continue
+		}
+		switch c := s[i]; {
+		case c == '"':
+			return i + 1, -1, ""
+		case c < 0x20:
+			if strict {
+				return -1, i, "control character in string"
+			}
+			i++
+		case c == '\\':
+			if i+1 == len(s) {
+				return -1, -1, ""
+			}
+			switch s[i+1] {
+			case '"', '\\', '/', 'b', 'f', 'n', 'r', 't':
+				i += 2
+			case 'u':
+				r, n := unescapeU(s[i:])
+				switch {
+				case n == 0 && strict:
+					return -1, i, "invalid escape sequence"
+				case n == 0:
+					i += 2
+				case validUTF8 && r == utf8.RuneError && n == 6 && utf16.IsSurrogate(getu4(s[i+2:i+6])):
+					return -1, i, "lone surrogate"
+				default:
+					i += n
+				}
+			default:
+				if strict {
+					return -1, i, "invalid escape sequence"
+				}
+				i += 2
+			}
+		case validUTF8:
+			r, n := utf8.DecodeRune(s[i:])
+			if r == utf8.RuneError && n == 1 {
+				return -1, i, "invalid UTF-8"
+			}
+			i += n
+		default:
+			i++
+		}
+	}
+	return -1, -1, ""
+}
+
+// checkNumber returns the offset in the number s where it stops matching
+// the grammar of RFC 8259, or -1 if it matches.
+func checkNumber(s []byte) int {
+	if end, ok := scanNumber(s, 0); !ok || end < len(s) {
+		return end
+	}
+	return -1
+}
+
+// scanNumber scans the longest prefix of s[i:] matching the grammar of
+// numbers in RFC 8259, and returns the location right after it. ok is false
+// if s[i:] doesn't start with a number, in which case end is where the
+// grammar stops matching, e.g. right after "1." or "-".
+func scanNumber(s []byte, i int) (end int, ok bool) {
+	if i < len(s) && s[i] == '-' {
+		i++
+	}
+	switch j := skipDigits(s, i); {
+	case i < len(s) && s[i] == '0':
+		i++
+	case j == i:
+		return i, false
+	default:
+		i = j
 	}
+	if i < len(s) && s[i] == '.' {
+		j := skipDigits(s, i+1)
+		if j == i+1 {
+			return j, false
+		}
+		i = j
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		i++
+		if i < len(s) && (s[i] == '+' || s[i] == '-') {
+			i++
+		}
+		j := skipDigits(s, i)
+		if j == i {
+			return i, false
+		}
+		i = j
+	}
+	return i, true
+}
+
+func skipDigits(s []byte, i int) int {
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return i
 }
 
-func Iterate(s []byte, cb func(typ TokenType, idx, len int)) error {
+// Iterate calls cb on each token in s, in which the types of object keys are
+// KEY, stopping at the first malformed token or misplaced comma.
+func Iterate(s []byte, cb func(typ TokenType, idx, len int), opts ...Option) error {
+	var cfg config
+	cfg.apply(opts)
 	hadComma, wantComma := false, false
+	comma := 0 // location of the last comma
+	lim := limiter{limits: &cfg.limits, maxDepth: cfg.maxDepth()}
 
 	for i := 0; i < len(s); {
 		i = skip(s, i)
 
 		currentType, length, errOnce := next(s, i)
+		if errOnce == nil && cfg.checked {
+			if errOnce = cfg.check(s, i, currentType, length); errOnce != nil {
+				currentType, length = INVALID, 0
+			}
+		}
 
 		start := i
 		// prepare for lookahead, consume until next char is valid
@@ -101,33 +229,97 @@ func Iterate(s []byte, cb func(typ TokenType, idx, len int)) error {
 				currentType = KEY
 				i++
 			} else {
-				return fmt.Errorf("%w at %d, expected string key", ErrUnexpectedToken, i)
+				return iterateError(s, newSyntaxError(ErrUnexpectedToken, start, "expected string key", KEY))
 			}
 		}
 
 		if currentType == END_ARRAY || currentType == END_OBJECT {
-			// intensionally don't check for previous comma
-			// if StrictComma && hadComma {
-			// 	return fmt.Errorf("%w at %d, unexpected comma", ErrUnexpectedSep, start-1)
-			// }
+			// trailing commas are only rejected in strict mode
+			if cfg.strict && hadComma {
+				return iterateError(s, newSyntaxError(ErrUnexpectedSep, comma, "unexpected trailing comma"))
+			}
 		} else if wantComma && !hadComma {
-			return fmt.Errorf("%w at %d, expected comma", ErrUnexpectedSep, start)
+			return iterateError(s, newSyntaxError(ErrUnexpectedSep, start, "expected comma"))
 		} else if !wantComma && hadComma {
-			return fmt.Errorf("%w at %d, unexpected comma", ErrUnexpectedSep, start-1)
+			return iterateError(s, newSyntaxError(ErrUnexpectedSep, comma, "unexpected comma"))
 		}
 		wantComma = commaAfterToken[currentType]
 		hadComma = i < len(s) && s[i] == ','
 		if hadComma {
+			comma = i
 			i++
 		}
 
+		if errOnce == nil {
+			if e := lim.read(currentType, start); e != nil {
+				return iterateError(s, e)
+			}
+		}
 		cb(currentType, start, length)
 		if errOnce != nil {
-			return fmt.Errorf("%w at %d", errOnce, start)
+			return iterateError(s, errOnce.(*SyntaxError))
+		}
+	}
+	if cfg.strict && hadComma {
+		return iterateError(s, newSyntaxError(ErrUnexpectedSep, comma, "unexpected trailing comma"))
+	}
+	return nil
+}
+
+func iterateError(s []byte, e *SyntaxError) error {
+	e.locate(s)
+	e.Path = pathAt(s, e.Offset)
+	return e
+}
+
+// limiter enforces the limits on depth, members and tokens for Iterate, which
+// reads tokens regardless of the structure.
+type limiter struct {
+	limits   *Limits
+	maxDepth int
+	depth    int
+	tokens   int
+	frames   []frame // containers being read, only if members are limited
+}
+
+// frame is a container being read.
+type frame struct {
+	open    byte // '{' or '['
+	members int
+}
+
+// read accounts for the token of type typ at pos.
+func (l *limiter) read(typ TokenType, pos int) *SyntaxError {
+	if typ == END_OBJECT || typ == END_ARRAY {
+		l.depth--
+		if len(l.frames) > 0 {
+			l.frames = l.frames[:len(l.frames)-1]
+		}
+		return nil
+	}
+	if max := l.limits.MaxMembers; max > 0 && len(l.frames) > 0 {
+		if f := &l.frames[len(l.frames)-1]; typ == KEY || f.open == '[' {
+			if f.members++; f.members > max {
+				return l.limits.exceeded(pos, "more members than", max)
+			}
+		}
+	}
+	if max := l.limits.MaxTokens; max > 0 {
+		if l.tokens++; l.tokens > max {
+			return l.limits.exceeded(pos, "more tokens than", max)
+		}
+	}
+	if typ == BEGIN_OBJECT || typ == BEGIN_ARRAY {
+		if l.depth++; l.depth > l.maxDepth {
+			return l.limits.exceeded(pos, "nesting deeper than", l.maxDepth)
+		}
+		if l.limits.MaxMembers > 0 {
+			f := frame{open: '['}
+			if typ == BEGIN_OBJECT {
+				f.open = '{'
+			}
+			l.frames = append(l.frames, f)
 		}
 	}
-	// if StrictComma && hadComma {
-	// 	return fmt.Errorf("%w at end, unexpected comma", ErrUnexpectedSep)
-	// }
 	return nil
 }
@@ -2,6 +2,7 @@ package jsontk
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"path"
@@ -56,3 +57,35 @@ func TestJSONDatasets(t *testing.T) {
 	// 	fmt.Printf("%s->%s\n", tk.Type.String(), string(tk.Value))
 	// }
 }
+
+func TestIterateError(t *testing.T) {
+	_, err := Tokenize([]byte(`{"a": [1, {"b": 1 "c": 2}]}`))
+	var se *SyntaxError
+	if !errors.As(err, &se) || !errors.Is(err, ErrUnexpectedSep) {
+		t.Fatalf("expected syntax error, got %v", err)
+	}
+	if se.Offset != 18 || se.Column != 19 || se.Found != STRING || se.Path != "$['a'][1]" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestIterateStrict(t *testing.T) {
+	for data, offset := range map[string]int{
+		`[1, 2,]`:           5,
+		`{"a": 1, }`:        7,
+		`[1,`:               2,
+		`{"a": 01}`:         7,
+		`{"a": ["\q"]}`:     8,
+		`{"a": "b` + "\n\"": 8,
+		`[1, {}]`:           -1,
+	} {
+		err := Iterate([]byte(data), func(TokenType, int, int) {}, Strict())
+		var se *SyntaxError
+		if offset < 0 && err != nil || offset >= 0 && (!errors.As(err, &se) || se.Offset != offset) {
+			t.Errorf("%s: unexpected error %v", data, err)
+		}
+	}
+	if err := Iterate([]byte(`[1, 2,]`), func(TokenType, int, int) {}); err != nil {
+		t.Errorf("trailing comma rejected when not strict: %v", err)
+	}
+}
@@ -1,7 +1,11 @@
 package jsontk
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"strconv"
 )
 
 var typMap = [256]TokenType{
@@ -14,11 +18,42 @@ var typMap = [256]TokenType{
 	']': END_ARRAY, '}': END_OBJECT,
 }
 
+const readerBufSize = 4096
+
 type Iterator struct {
 	data  []byte
 	head  int
 	Error error
 	key   Token // used for temporarily storing object keys to avoid alloc
+
+	// reader mode, see ResetReader
+	r    io.Reader
+	rerr error
+	rbuf []byte // window buffer owned by the iterator
+	kbuf []byte // copies of object keys, since the window may slide under them
+	pin  int    // the window won't slide while pin > 0
+	off  int    // stream offset of data[0]
+
+	lines     int // newlines slid out of the window
+	lineStart int // stream offset of the line containing data[0]
+	keyAt     int // window position of the last object key read
+
+	root []byte // document root for filter queries during Select
+
+	// unquoted keys of the objects being read, see DisallowDuplicateKeys
+	keys    []byte
+	keyEnds []int
+
+	depth   int         // objects and arrays being read
+	tokens  int         // values and object keys read, see Limits
+	counted int         // stream offset right after the last token counted
+	skips   []skipFrame // reused by skipContainer
+	opens   []byte      // reused by skipFast
+
+	index *Index // see ResetIndex
+	hint  int    // tape position to start searching the index from
+
+	cfg config // see Configure
 }
 
 func (iter *Iterator) Reset(data []byte) {
@@ -26,13 +61,201 @@ func (iter *Iterator) Reset(data []byte) {
 	iter.head = 0
 	iter.data = data
 	iter.key = Token{}
+	iter.r, iter.rerr = nil, nil
+	iter.kbuf = iter.kbuf[:0]
+	iter.pin, iter.off = 0, 0
+	iter.lines, iter.lineStart = 0, 0
+	iter.depth, iter.tokens, iter.counted = 0, 0, 0
+	iter.index = nil
+}
+
+// ResetReader makes the iterator read its input from r on demand.
+// Bytes are buffered in a window that slides forward as the iterator
+// advances, so memory usage depends on the size of the largest token
+// rather than the whole document, except for values kept whole: those
+// skipped with [Iterator.Skip], which returns their location, and those
+// kept by [Iterator.Select] as described there. Token values and locations
+// returned in reader mode are only valid before next call to ANY method on
+// [Iterator], and locations are read with [Iterator.Bytes].
+func (iter *Iterator) ResetReader(r io.Reader) {
+	if iter.rbuf == nil {
+		iter.rbuf = make([]byte, 0, readerBufSize)
+	}
+	iter.Reset(iter.rbuf[:0])
+	iter.r = r
+}
+
+// fill reads more bytes from the underlying reader into the window,
+// discarding consumed bytes if nothing is pinned. It reports whether
+// any new byte is available.
+func (iter *Iterator) fill() bool {
+	if iter.r == nil || iter.rerr != nil {
+		return false
+	}
+	if iter.pin == 0 && iter.head > 0 {
+		if nl := bytes.LastIndexByte(iter.data[:iter.head], '\n'); nl >= 0 {
+			iter.lines += bytes.Count(iter.data[:nl+1], []byte{'\n'})
+			iter.lineStart = iter.off + nl + 1
+		}
+		n := copy(iter.data, iter.data[iter.head:])
+		iter.off += iter.head
+		iter.data, iter.head = iter.data[:n], 0
+	}
+	if len(iter.data) == cap(iter.data) {
+		grown := make([]byte, len(iter.data), 2*cap(iter.data)+readerBufSize)
+		copy(grown, iter.data)
+		iter.data = grown
+	}
+	iter.rbuf = iter.data[:0]
+	for {
+		n, err := iter.r.Read(iter.data[len(iter.data):cap(iter.data)])
+		iter.data = iter.data[:len(iter.data)+n]
+		if err != nil {
+			iter.rerr = err
+			return n > 0
+		}
+		if n > 0 {
+			return true
+		}
+	}
+}
+
+// skipSpace moves head to the next non-whitespace byte, reading more
+// bytes if needed.
+func (iter *Iterator) skipSpace() {
+	iter.head = skip(iter.data, iter.head)
+	for iter.head >= len(iter.data) && iter.fill() {
+		iter.head = skip(iter.data, iter.head)
+	}
+}
+
+// next tokenizes at head. In reader mode, tokens truncated by the end
+// of the window are retried after reading more bytes.
+func (iter *Iterator) next() (TokenType, int, error) {
+	for {
+		typ, length, err := next(iter.data, iter.head)
+		if iter.r == nil {
+			if err == nil && iter.cfg.checked {
+				err = iter.check(typ, length)
+			}
+			if err != nil {
+				return INVALID, 0, iter.locate(err.(*SyntaxError))
+			}
+			return typ, length, err
+		}
+		if err == nil && (typ != NUMBER || iter.head+length < len(iter.data)) {
+			if iter.cfg.checked {
+				if err = iter.check(typ, length); err != nil {
+					return INVALID, 0, iter.locate(err.(*SyntaxError))
+				}
+			}
+			return typ, length, err
+		}
+		if err != nil && !errors.Is(err, ErrEarlyEOF) && len(iter.data)-iter.head >= 5 {
+			return typ, length, iter.locate(err.(*SyntaxError))
+		}
+		if !iter.fill() {
+			if err == nil && iter.cfg.checked {
+				err = iter.check(typ, length)
+			}
+			if err != nil {
+				if iter.rerr != io.EOF {
+					return typ, length, iter.readErr()
+				}
+				return INVALID, 0, iter.locate(err.(*SyntaxError))
+			}
+			return typ, length, err
+		}
+	}
+}
+
+// check checks the token at head against the options, counting it if it's
+// a scalar value or an object key.
+func (iter *Iterator) check(typ TokenType, length int) error {
+	if err := iter.cfg.check(iter.data, iter.head, typ, length); err != nil {
+		return err
+	}
+	if typ > END_ARRAY && !iter.count() {
+		return iter.cfg.limits.exceeded(iter.head, "more tokens than", iter.cfg.limits.MaxTokens)
+	}
+	return nil
+}
+
+// count counts the token at head against Limits.MaxTokens, reporting
+// whether it's within the limit. Tokens read again, e.g. by filters and
+// descendant segments in Select, are only counted the first time, so that
+// the limit bounds the document rather than how it's queried.
+func (iter *Iterator) count() bool {
+	if iter.cfg.limits.MaxTokens <= 0 {
+		return true
+	}
+	pos := iter.off + iter.head
+	if pos < iter.counted {
+		return true
+	}
+	iter.counted = pos + 1
+	iter.tokens++
+	return iter.tokens <= iter.cfg.limits.MaxTokens
+}
+
+// enter checks the limits on entering the container at head, in which
+// iter.depth is already increased.
+func (iter *Iterator) enter() error {
+	if max := iter.cfg.maxDepth(); iter.depth > max {
+		return iter.fail(ErrLimitExceeded, iter.head, false, "nesting deeper than "+strconv.Itoa(max))
+	}
+	if !iter.count() {
+		return iter.fail(ErrLimitExceeded, iter.head, false, "more tokens than "+strconv.Itoa(iter.cfg.limits.MaxTokens))
+	}
+	return nil
+}
+
+// member checks the limits on reading the n-th member of a container at pos.
+func (iter *Iterator) member(n, pos int) error {
+	if max := iter.cfg.limits.MaxMembers; max > 0 && n >= max {
+		return iter.fail(ErrLimitExceeded, pos, true, "more members than "+strconv.Itoa(max))
+	}
+	return nil
+}
+
+// readErr wraps the error returned by the underlying reader.
+func (iter *Iterator) readErr() error {
+	return fmt.Errorf("%w while reading input", iter.rerr)
+}
+
+// locate converts the window position of e into its position in the input.
+func (iter *Iterator) locate(e *SyntaxError) *SyntaxError {
+	e.locate(iter.data)
+	if iter.off > 0 {
+		if e.Line == 1 {
+			e.Column += iter.off - iter.lineStart
+		}
+		e.Line += iter.lines
+		e.Offset += iter.off
+	}
+	e.Path = "$"
+	return e
+}
+
+// fail records a syntax error at window position pos as iter.Error.
+// An error is owned if it's raised by a container about its own syntax,
+// see [prependPath].
+func (iter *Iterator) fail(err error, pos int, owned bool, msg string, expected ...TokenType) error {
+	if err == ErrEarlyEOF && iter.rerr != nil && iter.rerr != io.EOF {
+		iter.Error = iter.readErr()
+		return iter.Error
+	}
+	e := iter.locate(newSyntaxError(err, pos, msg, expected...))
+	e.owned = owned
+	iter.Error = e
+	return e
 }
 
 func (iter *Iterator) Peek() TokenType {
 	if iter.Error != nil {
 		return INVALID
 	}
-	iter.head = skip(iter.data, iter.head)
+	iter.skipSpace()
 	if iter.head >= len(iter.data) {
 		return INVALID
 	}
@@ -43,9 +266,13 @@ func (iter *Iterator) Next() (TokenType, int, int) {
 	if iter.Error != nil {
 		return INVALID, 0, 0
 	}
-	iter.head = skip(iter.data, iter.head)
-	loc := iter.head
-	typ, length, err := next(iter.data, iter.head)
+	iter.skipSpace()
+	typ, length, err := iter.next()
+	loc := iter.head // the window may have slid while tokenizing
+	if (typ == BEGIN_OBJECT || typ == BEGIN_ARRAY) && !iter.count() {
+		iter.fail(ErrLimitExceeded, loc, false, "more tokens than "+strconv.Itoa(iter.cfg.limits.MaxTokens))
+		return INVALID, loc, 0
+	}
 	iter.Error = err
 	iter.head += length
 	return typ, loc, length
@@ -63,77 +290,419 @@ func (iter *Iterator) NextToken(t *Token) *Token {
 	return t
 }
 
+// Unquote unquotes the string or key t read by the iterator, handling
+// invalid UTF-8 as configured by [ValidUTF8], unlike [Token.UnquoteBytes].
+func (iter *Iterator) Unquote(t *Token) ([]byte, bool) {
+	return unquoteBytesUTF8(t.Value, iter.cfg.utf8)
+}
+
+// EqualString reports whether the string or key t read by the iterator is s
+// once unquoted as with [Iterator.Unquote].
+func (iter *Iterator) EqualString(t *Token, s string) bool {
+	if iter.cfg.utf8 == UTF8Replace {
+		u, ok := unquoteBytesUTF8(t.Value, iter.cfg.utf8)
+		return ok && string(u) == s
+	}
+	return t.EqualString(s)
+}
+
+// Bytes returns the length bytes at loc, a location returned by Next or
+// Skip. They're part of the input in memory, or of the window in reader
+// mode, in which they're only valid before next call to ANY method on
+// [Iterator]. They must not be modified.
+func (iter *Iterator) Bytes(loc, length int) []byte {
+	return iter.data[loc : loc+length : loc+length]
+}
+
+// Skip skips over the next value. In reader mode, the window is not slid
+// while skipping, so that the returned location stays valid, which means
+// the whole value is buffered, see [Iterator.Bytes].
 func (iter *Iterator) Skip() (TokenType, int, int) {
+	if iter.Error == nil {
+		iter.skipSpace() // the bytes before may still slide out
+	}
+	iter.pin++
+	typ, loc, length := iter.skipValue()
+	iter.pin--
+	return typ, loc, length
+}
+
+// discard skips over the next value like Skip, but in reader mode lets the
+// window slide while skipping, so that values not needed aren't buffered.
+func (iter *Iterator) discard() {
+	iter.skipValue()
+}
+
+// skipValue skips over the next value, see Skip and discard.
+func (iter *Iterator) skipValue() (TokenType, int, int) {
 	if iter.Error != nil {
 		return INVALID, iter.head, 0
 	}
-	iter.head = skip(iter.data, iter.head)
+	iter.skipSpace()
+	typ, length, err := iter.next()
 	loc := iter.head
-	typ, length, err := next(iter.data, iter.head)
 	if err != nil {
 		iter.Error = err
 		return INVALID, iter.head, 0
 	}
-	switch typ {
-	case BEGIN_ARRAY:
-		iter.NextArray(nil)
-	case BEGIN_OBJECT:
+	switch {
+	case typ == BEGIN_OBJECT && iter.cfg.uniqueKeys:
+		// the keys of each object are tracked by NextObject, including the
+		// objects nested in arrays
 		iter.NextObject(nil)
+	case typ == BEGIN_ARRAY && iter.cfg.uniqueKeys:
+		iter.NextArray(nil)
+	case typ == BEGIN_ARRAY || typ == BEGIN_OBJECT:
+		if iter.index != nil && iter.skipIndexed() {
+			break
+		}
+		if iter.r == nil && !iter.cfg.checked && iter.cfg.limits.MaxMembers <= 0 {
+			var end int
+			iter.opens, end = skipFast(iter.data, iter.head, iter.cfg.maxDepth()-iter.depth, iter.opens[:0])
+			if end >= 0 {
+				iter.head = end
+				break
+			}
+			// read it again to locate the error
+		}
+		iter.skipContainer()
 	default:
 		iter.head += length
 	}
 	return typ, loc, iter.head - loc
 }
 
+// skipFast skips the object or array at i in s, which is in memory and read
+// without checks, returning the location right after it, or -1 if it's
+// malformed or nested deeper than maxDepth. Only the kinds of the containers
+// being read are kept, in stack.
+func skipFast(s []byte, i, maxDepth int, stack []byte) ([]byte, int) {
+enter:
+	for {
+		if len(stack) >= maxDepth {
+			return stack, -1
+		}
+		stack = append(stack, s[i])
+		i = skip(s, i+1)
+		for {
+			if i >= len(s) {
+				return stack, -1
+			}
+			if open := stack[len(stack)-1]; s[i] != open+2 { // not [] or a trailing comma
+				if open == '{' {
+					if s[i] != '"' {
+						return stack, -1
+					}
+					_, length, err := next(s, i)
+					if err != nil {
+						return stack, -1
+					}
+					if i = skip(s, i+length); i >= len(s) || s[i] != ':' {
+						return stack, -1
+					}
+					if i = skip(s, i+1); i >= len(s) {
+						return stack, -1
+					}
+				}
+				if s[i] == '{' || s[i] == '[' {
+					continue enter
+				}
+				typ, length, err := next(s, i)
+				if err != nil || typ == END_OBJECT || typ == END_ARRAY {
+					return stack, -1
+				}
+				if i = skip(s, i+length); i >= len(s) {
+					return stack, -1
+				}
+			}
+			// close the containers ending here, until a comma
+			for s[i] != ',' {
+				if s[i] != stack[len(stack)-1]+2 {
+					return stack, -1
+				}
+				if stack = stack[:len(stack)-1]; len(stack) == 0 {
+					return stack, i + 1
+				}
+				if i = skip(s, i+1); i >= len(s) {
+					return stack, -1
+				}
+			}
+			i = skip(s, i+1)
+		}
+	}
+}
+
+// skipFrame is a container being skipped.
+type skipFrame struct {
+	open        byte // '{' or '['
+	members     int
+	key, keyEnd int // location of the last object key, in kbuf in reader mode
+}
+
+// skipContainer skips the object or array at head in a single loop, keeping
+// the containers being read on a stack instead of recursing through Skip.
+// Tokens, limits and errors are checked just like NextObject and NextArray
+// would. In reader mode, the window may slide, so keys are copied for the
+// path of errors.
+func (iter *Iterator) skipContainer() {
+	stack, base, kbase := iter.skips[:0], iter.depth, len(iter.kbuf)
+	defer func() { iter.skips, iter.depth, iter.kbuf = stack[:0], base, iter.kbuf[:kbase] }()
+	for enter := true; ; {
+		if enter {
+			if iter.depth++; iter.enter() != nil {
+				break
+			}
+			stack = append(stack, skipFrame{open: iter.data[iter.head], key: len(iter.kbuf), keyEnd: len(iter.kbuf)})
+			iter.head++
+			enter = false
+		}
+		// read a member of the innermost container, or its end
+		f := &stack[len(stack)-1]
+		iter.skipSpace()
+		if iter.head >= len(iter.data) {
+			if f.open == '{' {
+				iter.fail(ErrEarlyEOF, iter.head, true, "while reading object, expecting object key or END_OBJECT", KEY, END_OBJECT)
+			} else {
+				iter.fail(ErrEarlyEOF, iter.head, true, "while reading array, expecting element or END_ARRAY")
+			}
+			break
+		}
+		closing := iter.data[iter.head] == f.open+2
+		if f.open == '{' && !closing {
+			typ, length, err := iter.next()
+			if err != nil {
+				if e, ok := err.(*SyntaxError); ok {
+					e.owned = true
+				}
+				iter.Error = err
+				break
+			}
+			if typ != STRING {
+				iter.fail(ErrUnexpectedToken, iter.head, true, "expected string key", KEY, END_OBJECT)
+				break
+			}
+			if iter.member(f.members, iter.head) != nil {
+				break
+			}
+			if iter.r != nil {
+				iter.kbuf = append(iter.kbuf[:f.key], iter.data[iter.head:iter.head+length]...)
+				f.keyEnd = len(iter.kbuf)
+			} else {
+				f.key, f.keyEnd = iter.head, iter.head+length
+			}
+			iter.head += length
+			iter.skipSpace()
+			if iter.head >= len(iter.data) || iter.data[iter.head] != ':' {
+				iter.fail(ErrUnexpectedToken, iter.head, true, "expected colon")
+				break
+			}
+			iter.head++
+		} else if closing && f.members > 0 && iter.cfg.strict {
+			iter.fail(ErrUnexpectedSep, iter.head, true, "unexpected trailing comma")
+			break
+		} else if !closing && iter.member(f.members, iter.head) != nil {
+			break
+		}
+		if !closing {
+			f.members++
+			iter.skipSpace()
+			typ, length, err := iter.next()
+			if err != nil {
+				iter.Error = err
+				break
+			}
+			if typ == BEGIN_OBJECT || typ == BEGIN_ARRAY {
+				enter = true
+				continue
+			}
+			iter.head += length
+		}
+		// close the containers ending here, until a comma
+		for ; ; closing = false {
+			if !closing {
+				iter.skipSpace()
+				if iter.head >= len(iter.data) {
+					if f.open == '{' {
+						iter.fail(ErrEarlyEOF, iter.head, true, "while reading object, expecting comma or END_OBJECT")
+					} else {
+						iter.fail(ErrEarlyEOF, iter.head, true, "while reading array, expecting comma or END_ARRAY")
+					}
+					break
+				}
+				if c := iter.data[iter.head]; c == ',' {
+					iter.head++
+					break
+				} else if c != f.open+2 {
+					if f.open == '{' {
+						iter.fail(ErrUnexpectedToken, iter.head, true, "expected comma or END_OBJECT", END_OBJECT)
+					} else {
+						iter.fail(ErrUnexpectedToken, iter.head, true, "expected comma or END_ARRAY", END_ARRAY)
+					}
+					break
+				}
+			}
+			iter.head++
+			iter.depth--
+			if stack = stack[:len(stack)-1]; len(stack) == 0 {
+				return
+			}
+			f = &stack[len(stack)-1]
+		}
+		if iter.Error != nil {
+			break
+		}
+	}
+	iter.skipError(stack, base)
+}
+
+// skipError prepends the path of the containers being skipped to iter.Error,
+// as if they were read by NextObject and NextArray. Errors not owned belong
+// to a member of the innermost container.
+func (iter *Iterator) skipError(stack []skipFrame, base int) {
+	top, keys := len(stack)-1, iter.data
+	if e, ok := iter.Error.(*SyntaxError); ok && e.owned {
+		top--
+	}
+	if iter.r != nil {
+		keys = iter.kbuf
+	}
+	for i := top; i >= 0; i-- {
+		f := &stack[i]
+		if f.open == '{' {
+			key := keys[f.key:f.keyEnd]
+			prependPath(iter.Error, func(b []byte) []byte { return appendNormalizedKey(b, key) }, base+i == 0)
+		} else {
+			idx := f.members - 1
+			prependPath(iter.Error, func(b []byte) []byte { return appendNormalizedIndex(b, idx) }, base+i == 0)
+		}
+	}
+}
+
 // NextObject iterates over the next value as an object, assuming that it is one.
 // One MUST be aware that the "key" callback parameter is only valid before next call to ANY method on [Iterator],
 // even within the callback body
 func (iter *Iterator) NextObject(cb func(key *Token) bool) error {
+	iter.depth++
+	if iter.r == nil && !iter.cfg.uniqueKeys {
+		err := iter.nextObject(cb)
+		iter.depth--
+		return err
+	}
+	base, keys, keyEnds := len(iter.kbuf), len(iter.keys), len(iter.keyEnds)
+	err := iter.nextObject(cb)
+	iter.kbuf, iter.keys, iter.keyEnds = iter.kbuf[:base], iter.keys[:keys], iter.keyEnds[:keyEnds]
+	iter.depth--
+	return err
+}
+
+// keySet is the set of keys read in an object, stored in iter.keys from
+// start, with their ends in iter.keyEnds from base. Large sets are moved into
+// a map.
+type keySet struct {
+	start, base int
+	m           map[string]struct{}
+}
+
+// add adds key to the set, reporting whether it's already there.
+func (ks *keySet) add(iter *Iterator, key []byte) bool {
+	if ks.m != nil {
+		u, _ := unquoteBytes(key)
+		if _, ok := ks.m[string(u)]; ok {
+			return true
+		}
+		ks.m[string(u)] = struct{}{}
+		return false
+	}
+	start := ks.start
+	for _, end := range iter.keyEnds[ks.base:] {
+		if unquotedEqual(key, iter.keys[start:end]) {
+			return true
+		}
+		start = end
+	}
+	u, _ := unquoteBytes(key)
+	if len(iter.keyEnds)-ks.base < 16 {
+		iter.keys = append(iter.keys, u...)
+		iter.keyEnds = append(iter.keyEnds, len(iter.keys))
+		return false
+	}
+	ks.m = make(map[string]struct{}, 32)
+	start = ks.start
+	for _, end := range iter.keyEnds[ks.base:] {
+		ks.m[string(iter.keys[start:end])] = struct{}{}
+		start = end
+	}
+	ks.m[string(u)] = struct{}{}
+	return false
+}
+
+func (iter *Iterator) nextObject(cb func(key *Token) bool) error {
 	if iter.Error != nil {
 		return iter.Error
 	}
-	iter.head = skip(iter.data, iter.head)
+	iter.skipSpace()
 	if iter.head >= len(iter.data) {
-		iter.Error = fmt.Errorf("%w while reading object", ErrEarlyEOF)
-		return iter.Error
+		return iter.fail(ErrEarlyEOF, iter.head, false, "while reading object", BEGIN_OBJECT)
 	}
 	if iter.data[iter.head] != '{' {
-		iter.Error = fmt.Errorf("%w at %d, expected BEGIN_OBJECT", ErrUnexpectedToken, iter.head)
-		return iter.Error
+		return iter.fail(ErrUnexpectedToken, iter.head, false, "expected BEGIN_OBJECT", BEGIN_OBJECT)
+	}
+	if err := iter.enter(); err != nil {
+		return err
 	}
 	iter.head++
-	for {
-		iter.head = skip(iter.data, iter.head)
+	base := len(iter.kbuf)
+	seen := keySet{start: len(iter.keys), base: len(iter.keyEnds)}
+	for n := 0; ; n++ {
+		iter.skipSpace()
 		if iter.head >= len(iter.data) {
-			iter.Error = fmt.Errorf("%w while reading object, expecting object key or END_OBJECT", ErrEarlyEOF)
-			return iter.Error
+			return iter.fail(ErrEarlyEOF, iter.head, true, "while reading object, expecting object key or END_OBJECT", KEY, END_OBJECT)
+		}
+		currentType, length, errOnce := iter.next()
+		if errOnce != nil {
+			if e, ok := errOnce.(*SyntaxError); ok {
+				e.owned = true
+			}
+			iter.Error = errOnce
+			return errOnce
 		}
-		currentType, length, errOnce := next(iter.data, iter.head)
-		iter.Error = errOnce
 		if currentType != STRING {
 			if currentType == END_OBJECT {
+				if n > 0 && iter.cfg.strict {
+					return iter.fail(ErrUnexpectedSep, iter.head, true, "unexpected trailing comma")
+				}
 				iter.head++
 				return nil
 			}
-			if iter.Error == nil {
-				iter.Error = fmt.Errorf("%w at %d, expected string key", ErrUnexpectedToken, iter.head)
-			}
-			return iter.Error
+			return iter.fail(ErrUnexpectedToken, iter.head, true, "expected string key", KEY, END_OBJECT)
+		}
+		if err := iter.member(n, iter.head); err != nil {
+			return err
 		}
+		iter.keyAt = iter.head
 		iter.key = Token{Type: KEY, Value: iter.data[iter.head : iter.head+length]}
-		iter.head = skip(iter.data, iter.head+length)
+		if iter.r != nil {
+			iter.kbuf = append(iter.kbuf[:base], iter.key.Value...)
+			iter.key.Value = iter.kbuf[base:]
+		}
+		if iter.cfg.uniqueKeys && seen.add(iter, iter.key.Value) {
+			return iter.fail(ErrDuplicateKey, iter.head, true, "key "+string(appendNormalizedKey(nil, iter.key.Value))+" seen before")
+		}
+		iter.head += length
+		iter.skipSpace()
 		if iter.head >= len(iter.data) || iter.data[iter.head] != ':' {
-			iter.Error = fmt.Errorf("%w at %d, expected colon", ErrUnexpectedToken, iter.head)
-			return iter.Error
+			return iter.fail(ErrUnexpectedToken, iter.head, true, "expected colon")
 		}
 		iter.head++
+		key := iter.key.Value
 		var interrupted bool
 		if cb == nil {
-			iter.Skip()
+			iter.discard()
 		} else {
 			interrupted = !cb(&iter.key)
 		}
 		if iter.Error != nil {
+			prependPath(iter.Error, func(b []byte) []byte { return appendNormalizedKey(b, key) }, iter.depth == 1)
 			return iter.Error
 		}
 		if interrupted {
@@ -141,15 +710,13 @@ func (iter *Iterator) NextObject(cb func(key *Token) bool) error {
 			return nil
 		}
 
-		iter.head = skip(iter.data, iter.head)
+		iter.skipSpace()
 		if iter.head >= len(iter.data) {
-			iter.Error = fmt.Errorf("%w while reading object, expecting comma or END_OBJECT", ErrEarlyEOF)
-			return iter.Error
+			return iter.fail(ErrEarlyEOF, iter.head, true, "while reading object, expecting comma or END_OBJECT")
 		}
 		if iter.data[iter.head] != ',' {
 			if iter.data[iter.head] != '}' {
-				iter.Error = fmt.Errorf("%w at %d, expected comma or END_OBJECT", ErrUnexpectedToken, iter.head)
-				return iter.Error
+				return iter.fail(ErrUnexpectedToken, iter.head, true, "expected comma or END_OBJECT", END_OBJECT)
 			}
 			iter.head++
 			return nil
@@ -159,52 +726,64 @@ func (iter *Iterator) NextObject(cb func(key *Token) bool) error {
 }
 
 func (iter *Iterator) NextArray(cb func(idx int) bool) error {
+	iter.depth++
+	err := iter.nextArray(cb)
+	iter.depth--
+	return err
+}
+
+func (iter *Iterator) nextArray(cb func(idx int) bool) error {
 	if iter.Error != nil {
 		return iter.Error
 	}
-	iter.head = skip(iter.data, iter.head)
+	iter.skipSpace()
 	if iter.head >= len(iter.data) {
-		iter.Error = fmt.Errorf("%w while reading array", ErrEarlyEOF)
-		return iter.Error
+		return iter.fail(ErrEarlyEOF, iter.head, false, "while reading array", BEGIN_ARRAY)
 	}
 	if iter.data[iter.head] != '[' {
-		iter.Error = fmt.Errorf("%w at %d, expected BEGIN_ARRAY", ErrUnexpectedToken, iter.head)
-		return iter.Error
+		return iter.fail(ErrUnexpectedToken, iter.head, false, "expected BEGIN_ARRAY", BEGIN_ARRAY)
+	}
+	if err := iter.enter(); err != nil {
+		return err
 	}
 	iter.head++
 
 	for idx := 0; ; idx++ {
-		iter.head = skip(iter.data, iter.head)
+		iter.skipSpace()
 		if iter.head >= len(iter.data) {
-			iter.Error = fmt.Errorf("%w while reading array, expecting element or END_ARRAY", ErrEarlyEOF)
-			return iter.Error
+			return iter.fail(ErrEarlyEOF, iter.head, true, "while reading array, expecting element or END_ARRAY")
 		}
 		if iter.data[iter.head] == ']' { // [] | [1,]
-			iter.head = skip(iter.data, iter.head+1)
+			if idx > 0 && iter.cfg.strict {
+				return iter.fail(ErrUnexpectedSep, iter.head, true, "unexpected trailing comma")
+			}
+			iter.head++
 			return nil
 		}
+		if err := iter.member(idx, iter.head); err != nil {
+			return err
+		}
 		var interrupted bool
 		if cb == nil {
-			iter.Skip()
+			iter.discard()
 		} else {
 			interrupted = !cb(idx)
 		}
 		if iter.Error != nil {
+			prependPath(iter.Error, func(b []byte) []byte { return appendNormalizedIndex(b, idx) }, iter.depth == 1)
 			return iter.Error
 		}
 		if interrupted {
 			iter.Error = fmt.Errorf("%w at %d", ErrInterrupt, iter.head)
 			return nil
 		}
-		iter.head = skip(iter.data, iter.head)
+		iter.skipSpace()
 		if iter.head >= len(iter.data) {
-			iter.Error = fmt.Errorf("%w while reading array, expecting comma or END_ARRAY", ErrEarlyEOF)
-			return iter.Error
+			return iter.fail(ErrEarlyEOF, iter.head, true, "while reading array, expecting comma or END_ARRAY")
 		}
 		if iter.data[iter.head] != ',' {
 			if iter.data[iter.head] != ']' {
-				iter.Error = fmt.Errorf("%w at %d, expected comma or END_ARRAY", ErrUnexpectedToken, iter.head)
-				return iter.Error
+				return iter.fail(ErrUnexpectedToken, iter.head, true, "expected comma or END_ARRAY", END_ARRAY)
 			}
 			iter.head++
 			return nil
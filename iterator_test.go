@@ -1,10 +1,15 @@
 package jsontk
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"io"
+	"os"
+	"path"
 	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 func TestIterator(t *testing.T) {
@@ -86,3 +91,479 @@ func TestEarlyEOF(t *testing.T) {
 		t.Error("[ should fail with EarlyEOF on NextArray")
 	}
 }
+
+func flatten(iter *Iterator, into []string) []string {
+	var tk Token
+	switch iter.Peek() {
+	case BEGIN_OBJECT:
+		iter.NextObject(func(key *Token) bool {
+			into = append(into, string(key.Value))
+			into = flatten(iter, into)
+			return true
+		})
+	case BEGIN_ARRAY:
+		iter.NextArray(func(idx int) bool {
+			into = flatten(iter, into)
+			return true
+		})
+	default:
+		iter.NextToken(&tk)
+		into = append(into, string(tk.Value))
+	}
+	return into
+}
+
+func TestIteratorReader(t *testing.T) {
+	entries, _ := os.ReadDir("./testdata")
+	for _, ent := range entries {
+		if ent.IsDir() {
+			continue
+		}
+		file, _ := os.ReadFile(path.Join("./testdata", ent.Name()))
+		var iter Iterator
+		iter.Reset(file)
+		want := flatten(&iter, nil)
+		if iter.Error != nil {
+			t.Fatal(iter.Error)
+		}
+		// tokens are split across reads at the start of the window, or
+		// anywhere in it
+		for _, r := range []io.Reader{iotest.OneByteReader(bytes.NewReader(file)), iotest.HalfReader(bytes.NewReader(file))} {
+			iter.ResetReader(r)
+			got := flatten(&iter, nil)
+			if iter.Error != nil {
+				t.Fatal(iter.Error)
+			}
+			if iter.Peek() != INVALID || iter.Error != nil {
+				t.Errorf("%s: expected EOF after value", ent.Name())
+			}
+			if strings.Join(got, "\x00") != strings.Join(want, "\x00") {
+				t.Errorf("%s: tokens mismatch in reader mode", ent.Name())
+			}
+		}
+	}
+}
+
+func TestIteratorReaderWindow(t *testing.T) {
+	big := "[" + strings.Repeat(`{"x": [1, "abc", null]}, `, 1<<16) + "{}]"
+	data := `{"big": ` + big + `, "a": [` + big + `, 1], "b": {"big": ` + big + `}}`
+	for _, path := range []string{`$.a[1]`, `$.b.c`, `$.*[1].x`} {
+		var iter Iterator
+		iter.ResetReader(strings.NewReader(data))
+		iter.Select(path, func(iter *Iterator) { iter.Skip() })
+		if iter.Error != nil {
+			t.Fatal(iter.Error)
+		}
+		if size := cap(iter.data); size > 64<<10 {
+			t.Errorf("%s: window grew to %d bytes for %d bytes of input", path, size, len(data))
+		}
+	}
+	// values skipped without the location returned aren't buffered either
+	var iter Iterator
+	iter.Configure(DisallowDuplicateKeys())
+	iter.ResetReader(strings.NewReader(data))
+	iter.NextObject(nil)
+	if size := cap(iter.data); iter.Error != nil || size > 64<<10 {
+		t.Errorf("window grew to %d bytes for %d bytes of input, %v", size, len(data), iter.Error)
+	}
+}
+
+func TestIteratorReaderSelect(t *testing.T) {
+	expt := Expectation(b("3", `"c"`))
+	var iter Iterator
+	iter.ResetReader(iotest.OneByteReader(strings.NewReader(`{"a": [1, 2, 3], "b": {"x": ["c"]}}`)))
+	iter.Select(`$..[-1]`, func(iter *Iterator) {
+		_, i, l := iter.Skip()
+		if v, ok := expt.Next(iter.Bytes(i, l)); !ok {
+			t.Errorf("result mismatch, expected %s, got %s", string(v), string(iter.Bytes(i, l)))
+		}
+	})
+	if iter.Error != nil {
+		t.Error(iter.Error)
+	}
+}
+
+func TestIteratorReaderError(t *testing.T) {
+	var iter Iterator
+	iter.ResetReader(io.MultiReader(strings.NewReader(`[1, 2`), iotest.ErrReader(iotest.ErrTimeout)))
+	err := iter.NextArray(func(int) bool {
+		iter.Skip()
+		return true
+	})
+	if !errors.Is(err, iotest.ErrTimeout) {
+		t.Errorf("expected reader error, got %v", err)
+	}
+	iter.ResetReader(strings.NewReader(`[1, 2`))
+	err = iter.NextArray(func(int) bool {
+		iter.Skip()
+		return true
+	})
+	if !errors.Is(err, ErrEarlyEOF) {
+		t.Errorf("expected early EOF, got %v", err)
+	}
+}
+
+func TestSyntaxError(t *testing.T) {
+	data := "{\"a\": [1, {\"b\": tru}],\n\"c\": 2}"
+	cases := []struct {
+		name  string
+		reset func(iter *Iterator)
+	}{
+		{"Bytes", func(iter *Iterator) { iter.Reset([]byte(data)) }},
+		{"Reader", func(iter *Iterator) { iter.ResetReader(iotest.OneByteReader(strings.NewReader(data))) }},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var iter Iterator
+			c.reset(&iter)
+			err := iter.Validate()
+			var se *SyntaxError
+			if !errors.As(err, &se) || !errors.Is(err, ErrUnexpectedToken) {
+				t.Fatalf("expected syntax error, got %v", err)
+			}
+			if se.Offset != 16 || se.Line != 1 || se.Column != 17 || se.Found != BOOLEAN {
+				t.Errorf("unexpected location: %v", se)
+			}
+			if se.Path != "$['a'][1]" {
+				t.Errorf("unexpected path %s", se.Path)
+			}
+		})
+	}
+	var iter Iterator
+	iter.ResetReader(iotest.OneByteReader(strings.NewReader("[\n1,\n2\n\"x\"]")))
+	err := iter.Validate()
+	var se *SyntaxError
+	if !errors.As(err, &se) || se.Offset != 7 || se.Line != 4 || se.Column != 1 || se.Found != STRING || se.Path != "$" {
+		t.Errorf("unexpected error %v", err)
+	}
+	iter.Reset([]byte(`{"a": {"b\"": 1, "c` + "\x01" + `": 2}}`))
+	err = iter.Validate()
+	if !errors.As(err, &se) || !errors.Is(err, ErrStandardViolation) || se.Offset != 19 || se.Path != "$['a']" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestStrict(t *testing.T) {
+	for _, tc := range []struct {
+		data   string
+		offset int
+		err    error
+	}{
+		{`[1, 2,]`, 6, ErrUnexpectedSep},
+		{`{"a": {"b": 1,}}`, 14, ErrUnexpectedSep},
+		{`[01]`, 2, ErrStandardViolation},
+		{`[-0.5e+10, 1.]`, 13, ErrStandardViolation},
+		{`[--1]`, 2, ErrStandardViolation},
+		{`{"a": 1e}`, 8, ErrStandardViolation},
+		{`[1.5.0]`, 4, ErrStandardViolation},
+		{`-`, 1, ErrStandardViolation},
+		{"[\"a\tb\"]", 3, ErrStandardViolation},
+		{`{"a\x": 1}`, 3, ErrStandardViolation},
+		{`["\u12g4"]`, 2, ErrStandardViolation},
+		{`["\"\\\/\b\f\n\r\té", 0, -0, 0.0e0, 1E-7, [], {}]`, -1, nil},
+	} {
+		for name, reset := range map[string]func(iter *Iterator){
+			"Bytes":  func(iter *Iterator) { iter.Reset([]byte(tc.data)) },
+			"Reader": func(iter *Iterator) { iter.ResetReader(iotest.OneByteReader(strings.NewReader(tc.data))) },
+		} {
+			var iter Iterator
+			iter.Configure(Strict())
+			reset(&iter)
+			err := iter.Validate()
+			var se *SyntaxError
+			if tc.err == nil {
+				if err != nil {
+					t.Errorf("%s %s: unexpected error %v", name, tc.data, err)
+				}
+			} else if !errors.Is(err, tc.err) || !errors.As(err, &se) || se.Offset != tc.offset {
+				t.Errorf("%s %s: expected %v at %d, got %v", name, tc.data, tc.err, tc.offset, err)
+			}
+		}
+		var iter Iterator
+		iter.Reset([]byte(tc.data))
+		if iter.Skip(); tc.err == ErrUnexpectedSep && iter.Error != nil {
+			t.Errorf("%s: rejected when not strict", tc.data)
+		}
+	}
+}
+
+func TestValidUTF8(t *testing.T) {
+	data := "{\"a\": [\"ok\", \"\\uD83D\\uDE00\"], \"b\xff\": 1, \"c\": \"\\uDE00\"}"
+	var iter Iterator
+	iter.Configure(ValidUTF8(UTF8Reject))
+	iter.Reset([]byte(data))
+	err := iter.Validate()
+	var se *SyntaxError
+	if !errors.As(err, &se) || !errors.Is(err, ErrStandardViolation) || se.Offset != 32 {
+		t.Errorf("unexpected error %v", err)
+	}
+	iter.Reset([]byte(`["\uDE00"]`))
+	if err := iter.Validate(); !errors.As(err, &se) || se.Offset != 2 || !strings.Contains(err.Error(), "lone surrogate") {
+		t.Errorf("unexpected error %v", err)
+	}
+	err = Iterate([]byte(data), func(TokenType, int, int) {}, ValidUTF8(UTF8Reject))
+	if !errors.As(err, &se) || se.Offset != 32 {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	var keys, values []string
+	iter.Configure(ValidUTF8(UTF8Replace))
+	iter.Reset([]byte(data))
+	iter.NextObject(func(key *Token) bool {
+		k, _ := iter.Unquote(key)
+		keys = append(keys, string(k))
+		if iter.EqualString(key, "b\ufffd") {
+			values = append(values, "1")
+		}
+		if iter.Peek() == STRING {
+			s, _ := iter.Unquote(iter.NextToken(nil))
+			values = append(values, string(s))
+		} else {
+			iter.Skip()
+		}
+		return true
+	})
+	if iter.Error != nil || strings.Join(keys, " ") != "a b\ufffd c" || strings.Join(values, " ") != "1 \ufffd" {
+		t.Errorf("unexpected result %q %q, %v", keys, values, iter.Error)
+	}
+}
+
+func TestDuplicateKeys(t *testing.T) {
+	var big strings.Builder
+	big.WriteString(`{"x": {`)
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(&big, `"k%d": %d, `, i, i)
+	}
+	big.WriteString(`"k50": 0}}`)
+	for _, tc := range []struct {
+		data, path string
+		offset     int
+	}{
+		{`{"role":"user","role":"admin"}`, "$", 15},
+		{`{"a": [{"b": 1}, {"b": 1, "c": {"b": 1}, "b": 2}]}`, "$['a'][1]", 41},
+		{`{"a": 1, "b": {"a": 1}, "aé": 2, "a\u00e9": 3}`, "$", 34},
+		{big.String(), "$['x']", big.Len() - 10},
+		{`[{"a": 1}, {"a": 2}, {"a": {"a": {}}}]`, "", -1},
+	} {
+		for name, reset := range map[string]func(iter *Iterator){
+			"Bytes":  func(iter *Iterator) { iter.Reset([]byte(tc.data)) },
+			"Reader": func(iter *Iterator) { iter.ResetReader(iotest.HalfReader(strings.NewReader(tc.data))) },
+		} {
+			var iter Iterator
+			iter.Configure(DisallowDuplicateKeys())
+			reset(&iter)
+			err := iter.Validate()
+			var se *SyntaxError
+			if tc.offset < 0 {
+				if err != nil {
+					t.Errorf("%s %.32s: unexpected error %v", name, tc.data, err)
+				}
+			} else if !errors.Is(err, ErrDuplicateKey) || !errors.As(err, &se) || se.Offset != tc.offset || se.Path != tc.path {
+				t.Errorf("%s %.32s: expected duplicate at %d in %s, got %v", name, tc.data, tc.offset, tc.path, err)
+			}
+		}
+		if err := Validate([]byte(tc.data)); err != nil {
+			t.Errorf("%.32s: rejected by default: %v", tc.data, err)
+		}
+	}
+	err := Validate([]byte(`{"a": {"b": 1, "b": 2}}`), DisallowDuplicateKeys())
+	if !errors.Is(err, ErrDuplicateKey) || !strings.Contains(err.Error(), "key ['b'] seen before, in $['a']") {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	// skipped values, including objects nested in arrays, are checked too
+	for data, skip := range map[string]func(iter *Iterator) error{
+		`[{"a": 1, "a": 2}]`:          func(iter *Iterator) error { iter.Skip(); return iter.Error },
+		`[[1, {"a": 1, "a": 2}]]`:     func(iter *Iterator) error { iter.Skip(); return iter.Error },
+		`{"x": [{"a": 1, "a": 2}]}`:   func(iter *Iterator) error { return iter.Select("$.y", func(*Iterator) {}) },
+		`{"x": [[{"a": 1, "a": 2}]]}`: func(iter *Iterator) error { return iter.Select("$.x[1]", func(*Iterator) {}) },
+	} {
+		for name, reset := range map[string]func(iter *Iterator){
+			"Bytes":  func(iter *Iterator) { iter.Reset([]byte(data)) },
+			"Reader": func(iter *Iterator) { iter.ResetReader(iotest.HalfReader(strings.NewReader(data))) },
+		} {
+			var iter Iterator
+			iter.Configure(DisallowDuplicateKeys())
+			reset(&iter)
+			if err := skip(&iter); !errors.Is(err, ErrDuplicateKey) {
+				t.Errorf("%s %s: expected ErrDuplicateKey, got %v", name, data, err)
+			}
+		}
+	}
+}
+
+func TestLimits(t *testing.T) {
+	deep := []byte(strings.Repeat("[", 1<<20))
+	var iter Iterator
+	for name, f := range map[string]func() error{
+		"Validate": func() error { return Validate(deep) },
+		"Iterate":  func() error { return Iterate(deep, func(TokenType, int, int) {}) },
+		"Skip":     func() error { iter.Reset(deep); iter.Skip(); return iter.Error },
+		"Select":   func() error { iter.Reset(deep); return iter.Select("$..a", func(*Iterator) {}) },
+		"Patch": func() error {
+			_, _, err := Patch(deep, "$..*", func(v []byte) []byte { return v })
+			return err
+		},
+	} {
+		var se *SyntaxError
+		if err := f(); !errors.Is(err, ErrLimitExceeded) || !errors.As(err, &se) || se.Offset != DefaultMaxDepth {
+			t.Errorf("%s: unexpected error %v", name, err)
+		}
+	}
+
+	for _, tc := range []struct {
+		limits Limits
+		data   string
+		offset int
+	}{
+		{Limits{MaxDepth: 2}, `[{"a": [1]}]`, 7},
+		{Limits{MaxDepth: 2}, `[{"a": []}, {}]`, 7},
+		{Limits{MaxDepth: 3}, `[{"a": [1]}]`, -1},
+		{Limits{MaxDepth: -1}, strings.Repeat("[", DefaultMaxDepth+1) + strings.Repeat("]", DefaultMaxDepth+1), -1},
+		{Limits{MaxStringLength: 4}, `{"ab": "abc"}`, 7},
+		{Limits{MaxStringLength: 4}, `{"abc": 1}`, 1},
+		{Limits{MaxStringLength: 4}, `{"ab": "ab"}`, -1},
+		{Limits{MaxNumberLength: 3}, `[123, 1234]`, 6},
+		{Limits{MaxMembers: 2}, `[1, 2, 3]`, 7},
+		{Limits{MaxMembers: 2}, `{"a": [1, 2], "b": {}, "c": 3}`, 23},
+		{Limits{MaxMembers: 2}, `{"a": [1, 2], "b": {"c": 1, "d": 2}}`, -1},
+		{Limits{MaxTokens: 5}, `{"a": [1, 2], "b": 3}`, 14},
+		{Limits{MaxTokens: 5}, `{"a": [1, 2]}`, -1},
+	} {
+		for name, reset := range map[string]func(iter *Iterator){
+			"Bytes":  func(iter *Iterator) { iter.Reset([]byte(tc.data)) },
+			"Reader": func(iter *Iterator) { iter.ResetReader(iotest.OneByteReader(strings.NewReader(tc.data))) },
+		} {
+			var iter Iterator
+			iter.Configure(WithLimits(tc.limits))
+			reset(&iter)
+			err := iter.Validate()
+			var se *SyntaxError
+			if tc.offset < 0 && err != nil || tc.offset >= 0 && (!errors.Is(err, ErrLimitExceeded) || !errors.As(err, &se) || se.Offset != tc.offset) {
+				t.Errorf("%s %+v %.32s: unexpected error %v", name, tc.limits, tc.data, err)
+			}
+		}
+		err := Iterate([]byte(tc.data), func(TokenType, int, int) {}, WithLimits(tc.limits))
+		var se *SyntaxError
+		if tc.offset < 0 && err != nil || tc.offset >= 0 && (!errors.Is(err, ErrLimitExceeded) || !errors.As(err, &se) || se.Offset != tc.offset) {
+			t.Errorf("Iterate %+v %.32s: unexpected error %v", tc.limits, tc.data, err)
+		}
+	}
+}
+
+func TestLimitsSelect(t *testing.T) {
+	data := `{"a": {"d": 1, "b": [1, 2]}, "c": [{"d": 2}, [3]], "d": {"d": 4}}`
+	tokens := 0
+	Iterate([]byte(data), func(typ TokenType, _, _ int) {
+		if typ != END_OBJECT && typ != END_ARRAY {
+			tokens++
+		}
+	})
+	// values read again by the query count once, so the document passes
+	// with as many tokens as Validate
+	for _, path := range []string{`$..d`, `$..[?@.d]`, `$.c[-1:]`, `$[?$.d.d == 4]`, `$['a','a','d']..*`} {
+		for name, reset := range map[string]func(iter *Iterator){
+			"Bytes":  func(iter *Iterator) { iter.Reset([]byte(data)) },
+			"Reader": func(iter *Iterator) { iter.ResetReader(iotest.OneByteReader(strings.NewReader(data))) },
+		} {
+			for _, max := range []int{tokens - 1, tokens} {
+				var iter Iterator
+				iter.Configure(WithLimits(Limits{MaxTokens: max}))
+				reset(&iter)
+				err := iter.Select(path, func(iter *Iterator) { iter.Skip() })
+				if max == tokens && err != nil || max < tokens && !errors.Is(err, ErrLimitExceeded) {
+					t.Errorf("%s %s with %d tokens: unexpected error %v", name, path, max, err)
+				}
+			}
+		}
+	}
+}
+
+func TestLimitsOptions(t *testing.T) {
+	data := []byte(`{"a": [[1]], "b": 2}`)
+	id := func(v []byte) []byte { return v }
+	for name, f := range map[string]func(opts ...Option) error{
+		"Patch":       func(opts ...Option) error { _, _, err := Patch(data, "$.b", id, opts...); return err },
+		"AppendPatch": func(opts ...Option) error { _, _, err := AppendPatch(nil, data, "$.b", id, opts...); return err },
+		"Delete":      func(opts ...Option) error { _, _, err := Delete(data, "$.b", opts...); return err },
+		"InsertMember": func(opts ...Option) error {
+			_, _, err := InsertMember(data, "$", 0, "c", []byte(`3`), opts...)
+			return err
+		},
+		"InsertElement": func(opts ...Option) error {
+			_, _, err := InsertElement(data, "$.a", 0, []byte(`3`), opts...)
+			return err
+		},
+		"ApplyPatch": func(opts ...Option) error {
+			_, err := ApplyPatch(data, []byte(`[{"op": "remove", "path": "/b"}]`), opts...)
+			return err
+		},
+		"MergePatch": func(opts ...Option) error { _, err := MergePatch(data, []byte(`{"b": null}`), opts...); return err },
+		"MergePatch patch": func(opts ...Option) error {
+			_, err := MergePatch([]byte(`{}`), []byte(`{"b": [[[1]]]}`), opts...)
+			return err
+		},
+	} {
+		if err := f(); err != nil {
+			t.Errorf("%s: unexpected error %v", name, err)
+		}
+		if err := f(WithLimits(Limits{MaxDepth: 2})); !errors.Is(err, ErrLimitExceeded) {
+			t.Errorf("%s: expected ErrLimitExceeded, got %v", name, err)
+		}
+	}
+
+	// queries in filters are run with the limits of the iterator
+	deep := []byte(`[1, ` + strings.Repeat("[", DefaultMaxDepth) + `{"x": 1}` + strings.Repeat("]", DefaultMaxDepth) + `]`)
+	var iter Iterator
+	iter.Configure(WithLimits(Limits{MaxDepth: -1}))
+	iter.Reset(deep)
+	n := 0
+	if err := iter.Select("$[?$..x]", func(iter *Iterator) { iter.Skip(); n++ }); err != nil || n != 2 {
+		t.Errorf("selected %d values with error %v, want 2", n, err)
+	}
+}
+
+// skipRecursive skips the next value like Skip did before it was flattened.
+func skipRecursive(iter *Iterator) {
+	switch iter.Peek() {
+	case BEGIN_OBJECT:
+		iter.NextObject(func(*Token) bool { skipRecursive(iter); return true })
+	case BEGIN_ARRAY:
+		iter.NextArray(func(int) bool { skipRecursive(iter); return true })
+	default:
+		iter.Next()
+	}
+}
+
+func FuzzSkip(f *testing.F) {
+	for _, seed := range []string{
+		`{"a":[1,{"b":[]},"x"],"c":{"d":{}}} 1`, `[1,]`, `{"a":1,}`, `[[1],[2,[3 4]]]`, `{"a":{"b" 1}}`,
+		`[{"a":1},{"b":2,"c"}]`, `{"a":[1,2,3]}`, `[[[[[]]]]]`, `[1}`, `{"a":[}`, `[[1],`, `{"a"`, `]`, `[01]`,
+	} {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		for _, opts := range [][]Option{
+			nil,
+			{WithLimits(Limits{MaxDepth: 3})},
+			{Strict(), ValidUTF8(UTF8Reject)},
+			{WithLimits(Limits{MaxDepth: 3, MaxMembers: 2, MaxTokens: 6})},
+		} {
+			var got, want Iterator
+			got.Configure(opts...)
+			want.Configure(opts...)
+			got.Reset(data)
+			want.Reset(data)
+			got.Skip()
+			skipRecursive(&want)
+			if fmt.Sprint(got.Error) != fmt.Sprint(want.Error) || want.Error == nil && got.head != want.head {
+				t.Fatalf("%q: Skip stopped at %d with %v, want %d with %v", data, got.head, got.Error, want.head, want.Error)
+			}
+			got.ResetReader(iotest.OneByteReader(bytes.NewReader(data)))
+			got.Skip()
+			if fmt.Sprint(got.Error) != fmt.Sprint(want.Error) {
+				t.Fatalf("%q: Skip in reader mode failed with %v, want %v", data, got.Error, want.Error)
+			}
+		}
+	})
+}
@@ -14,9 +14,16 @@ var iterPool = sync.Pool{New: func() any { return &jsontk.Iterator{} }}
 // Unmarshal decodes JSON-encoded data and stores the result
 // just like json.Unmarshal from the standard library.
 // plain interface is currently not supported as a target type.
-func Unmarshal(data []byte, into interface{}) error {
-	iter := iterPool.Get().(*jsontk.Iterator)
-	defer iterPool.Put(iter)
+// data is read with the options, e.g. [jsontk.WithLimits] for untrusted input.
+func Unmarshal(data []byte, into interface{}, opts ...jsontk.Option) error {
+	var iter *jsontk.Iterator
+	if len(opts) == 0 {
+		iter = iterPool.Get().(*jsontk.Iterator)
+		defer iterPool.Put(iter)
+	} else {
+		iter = new(jsontk.Iterator) // options are kept, pooled iterators mustn't have any
+		iter.Configure(opts...)
+	}
 	iter.Reset(data)
 	v := reflect.ValueOf(into)
 	if v.Kind() != reflect.Pointer {
@@ -28,7 +35,8 @@ func Unmarshal(data []byte, into interface{}) error {
 func writeStruct(iter *jsontk.Iterator, v reflect.Value) error {
 	sc := cachedStructIndex(v.Type())
 	return iter.NextObject(func(key *jsontk.Token) bool {
-		fn := key.String()
+		k, _ := iter.Unquote(key)
+		fn := string(k)
 		field, ok := sc[fn]
 		if !ok {
 			iter.Skip()
@@ -39,7 +47,7 @@ func writeStruct(iter *jsontk.Iterator, v reflect.Value) error {
 			iter.Error = fmt.Errorf("invalid field %s", fn)
 		}
 		if err := writeVal(iter, f); err != nil {
-			iter.Error = fmt.Errorf("%w for field %s", err, fn)
+			iter.Error = wrapf(err, " for field %s", fn)
 			return false
 		}
 		return true
@@ -54,10 +62,12 @@ func writeMap(iter *jsontk.Iterator, v reflect.Value) error {
 	valType := v.Type().Elem()
 	mkey := reflect.New(keyType).Elem()
 	return iter.NextObject(func(key *jsontk.Token) bool {
-		mkey.SetString(key.String())
+		k, _ := iter.Unquote(key)
+		name := string(k)
+		mkey.SetString(name)
 		val := reflect.New(valType).Elem()
 		if err := writeVal(iter, val); err != nil {
-			iter.Error = fmt.Errorf("%w for key %s", err, key.String())
+			iter.Error = wrapf(err, " for key %s", name)
 			return false
 		}
 		v.SetMapIndex(mkey, val)
@@ -79,7 +89,7 @@ func writeSlice(iter *jsontk.Iterator, v reflect.Value) error {
 		}
 		v.SetLen(idx + 1)
 		if err := writeVal(iter, v.Index(idx)); err != nil {
-			iter.Error = fmt.Errorf("%w at index %d", err, idx)
+			iter.Error = wrapf(err, " at index %d", idx)
 			return false
 		}
 		return true
@@ -103,6 +113,16 @@ func writeArray(iter *jsontk.Iterator, v reflect.Value) error {
 	})
 }
 
+// wrapf annotates err with the member being decoded. Syntax errors already
+// carry their path, and are returned as is so that deeply nested ones stay
+// cheap.
+func wrapf(err error, format string, args ...any) error {
+	if _, ok := err.(*jsontk.SyntaxError); ok {
+		return err
+	}
+	return fmt.Errorf("%w"+format, append([]any{err}, args...)...)
+}
+
 var jsonUnmarshaller = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
 
 func writeVal(iter *jsontk.Iterator, f reflect.Value) error {
@@ -167,7 +187,7 @@ func writeVal(iter *jsontk.Iterator, f reflect.Value) error {
 		if tk.Type == jsontk.INVALID {
 			return fmt.Errorf("invalid string: %w", iter.Error)
 		}
-		s, ok := tk.UnquoteBytes()
+		s, ok := iter.Unquote(&tk)
 		if !ok {
 			return fmt.Errorf("invalid string: unquote failed")
 		}
@@ -2,8 +2,12 @@ package json
 
 import (
 	"encoding/json"
+	"errors"
 	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/frankli0324/go-jsontk"
 )
 
 func assert(t *testing.T, b bool) {
@@ -249,3 +253,28 @@ func TestStrangeBehavior(t *testing.T) {
 		assert(t, m["test"] == 1)
 	})
 }
+
+func TestJSONUnmarshal_Deep(t *testing.T) {
+	type nested []nested
+	var v nested
+	err := Unmarshal([]byte(strings.Repeat("[", 1<<20)), &v)
+	assert(t, errors.Is(err, jsontk.ErrLimitExceeded))
+	err = Unmarshal([]byte(`[[[]], []]`), &v)
+	assert(t, err == nil && len(v) == 2 && len(v[0]) == 1)
+}
+
+func TestJSONUnmarshal_Options(t *testing.T) {
+	var v Group
+	limits := jsontk.WithLimits(jsontk.Limits{MaxMembers: 2})
+	err := Unmarshal([]byte(`{"tags": ["a", "b", "c"]}`), &v, limits)
+	assert(t, errors.Is(err, jsontk.ErrLimitExceeded))
+	// the limits don't stick to pooled iterators
+	err = Unmarshal([]byte(`{"tags": ["a", "b", "c"]}`), &v)
+	assert(t, err == nil && len(v.Tags) == 3)
+}
+
+func TestJSONUnmarshal_UTF8(t *testing.T) {
+	var v map[string]string
+	err := Unmarshal([]byte("{\"a\xff\": \"b\xff\"}"), &v, jsontk.ValidUTF8(jsontk.UTF8Replace))
+	assert(t, err == nil && v["a�"] == "b�")
+}
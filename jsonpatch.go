@@ -0,0 +1,311 @@
+package jsontk
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// PatchError is returned by [ApplyPatch] if an operation can't be applied.
+type PatchError struct {
+	Index int    // index of the operation in the patch
+	Op    string // e.g. "add", "test"
+	Path  string // the path member of the operation
+	Err   error
+}
+
+func (e *PatchError) Error() string {
+	return fmt.Sprintf("json patch operation %d (%s %s): %v", e.Index, e.Op, e.Path, e.Err)
+}
+
+func (e *PatchError) Unwrap() error {
+	return e.Err
+}
+
+// invalidPatchError is ErrInvalidPatch caused by err, e.g. a *SyntaxError,
+// so that errors.Is and errors.As reach both.
+type invalidPatchError struct {
+	msg string
+	err error
+}
+
+func (e *invalidPatchError) Error() string {
+	return ErrInvalidPatch.Error() + ": " + e.msg + e.err.Error()
+}
+
+func (e *invalidPatchError) Is(target error) bool {
+	return target == ErrInvalidPatch
+}
+
+func (e *invalidPatchError) Unwrap() error {
+	return e.err
+}
+
+type patchOp struct {
+	op, path, from string
+	value          []byte // raw json, nil if absent
+	hasPath        bool
+	hasFrom        bool
+}
+
+// ApplyPatch applies a JSON Patch (RFC 6902) to data. Values are located
+// with an [Iterator] and the result is spliced from byte ranges of data, so
+// that bytes untouched by the patch are kept as is. data itself is never
+// modified. Operations are applied in order, and if one of them fails, a
+// *[PatchError] is returned. Both data and patch are read with the options.
+func ApplyPatch(data, patch []byte, opts ...Option) ([]byte, error) {
+	var cfg config
+	cfg.apply(opts)
+	ops, err := parsePatch(patch, &cfg)
+	if err != nil {
+		return nil, err
+	}
+	for i, op := range ops {
+		if data, err = op.apply(data, &cfg); err != nil {
+			return nil, &PatchError{Index: i, Op: op.op, Path: op.path, Err: err}
+		}
+	}
+	return data, nil
+}
+
+func parsePatch(patch []byte, cfg *config) (ops []patchOp, err error) {
+	var iter Iterator
+	iter.cfg = *cfg
+	iter.Reset(patch)
+	str := func(dst *string) bool {
+		if iter.Peek() != STRING {
+			iter.Skip()
+			return false
+		}
+		var tk Token
+		s, ok := iter.NextToken(&tk).UnquoteBytes()
+		*dst = string(s)
+		return ok
+	}
+	iter.NextArray(func(idx int) bool {
+		var op patchOp
+		ok := true
+		iter.NextObject(func(key *Token) bool {
+			switch {
+			case key.EqualString("op"):
+				ok = str(&op.op) && ok
+			case key.EqualString("path"):
+				ok, op.hasPath = str(&op.path) && ok, true
+			case key.EqualString("from"):
+				ok, op.hasFrom = str(&op.from) && ok, true
+			case key.EqualString("value"):
+				_, loc, length := iter.Skip()
+				op.value = patch[loc : loc+length]
+			default:
+				iter.Skip()
+			}
+			return true
+		})
+		if iter.Error != nil {
+			err = &PatchError{Index: idx, Op: op.op, Path: op.path, Err: &invalidPatchError{err: iter.Error}}
+			return false
+		}
+		if err = op.check(ok, cfg); err != nil {
+			err = &PatchError{Index: idx, Op: op.op, Path: op.path, Err: err}
+			return false
+		}
+		ops = append(ops, op)
+		return true
+	})
+	if iter.Error != nil && err == nil {
+		return nil, &invalidPatchError{err: iter.Error}
+	}
+	return ops, err
+}
+
+// check reports whether the members of the operation are valid.
+func (op *patchOp) check(ok bool, cfg *config) error {
+	if !ok {
+		return fmt.Errorf("%w: members must be strings", ErrInvalidPatch)
+	}
+	switch op.op {
+	case "add", "replace", "test":
+		if op.value == nil {
+			return fmt.Errorf("%w: missing value", ErrInvalidPatch)
+		}
+		if err := validateValue(op.value, cfg); err != nil {
+			return &invalidPatchError{msg: "invalid value, ", err: err}
+		}
+	case "move", "copy":
+		if !op.hasFrom {
+			return fmt.Errorf("%w: missing from", ErrInvalidPatch)
+		}
+	case "remove":
+	default:
+		return fmt.Errorf("%w: unknown op %q", ErrInvalidPatch, op.op)
+	}
+	if !op.hasPath {
+		return fmt.Errorf("%w: missing path", ErrInvalidPatch)
+	}
+	return nil
+}
+
+func (op *patchOp) apply(data []byte, cfg *config) ([]byte, error) {
+	path, err := CompilePointer(op.path)
+	if err != nil {
+		return nil, err
+	}
+	var from *Pointer
+	if op.hasFrom {
+		if from, err = CompilePointer(op.from); err != nil {
+			return nil, err
+		}
+	}
+	switch op.op {
+	case "add":
+		return patchAdd(data, path.tokens, op.value, cfg)
+	case "remove":
+		return patchRemove(data, path.tokens, cfg)
+	case "replace":
+		loc, end, err := resolvePointer(data, path.tokens, cfg)
+		if err != nil {
+			return nil, err
+		}
+		return splice(data, loc, end, op.value), nil
+	case "move":
+		if strings.HasPrefix(op.path, op.from+"/") {
+			return nil, fmt.Errorf("%w: can't move a value into itself", ErrInvalidPatch)
+		}
+		loc, end, err := resolvePointer(data, from.tokens, cfg)
+		if err != nil || op.path == op.from {
+			return data, err
+		}
+		value := append([]byte(nil), data[loc:end]...)
+		if data, err = patchRemove(data, from.tokens, cfg); err != nil {
+			return nil, err
+		}
+		return patchAdd(data, path.tokens, value, cfg)
+	case "copy":
+		loc, end, err := resolvePointer(data, from.tokens, cfg)
+		if err != nil {
+			return nil, err
+		}
+		return patchAdd(data, path.tokens, append([]byte(nil), data[loc:end]...), cfg)
+	default: // test
+		loc, end, err := resolvePointer(data, path.tokens, cfg)
+		if err != nil {
+			return nil, err
+		}
+		value := bytes.TrimLeft(op.value, " \t\r\n")
+		if !valueEqual(Token{Type: typMap[data[loc]], Value: data[loc:end]}, Token{Type: typMap[value[0]], Value: value}) {
+			return nil, fmt.Errorf("%w: value at %s is %s", ErrTestFailed, op.path, data[loc:end])
+		}
+		return data, nil
+	}
+}
+
+func patchAdd(data []byte, tokens []string, value []byte, cfg *config) ([]byte, error) {
+	if len(tokens) == 0 {
+		loc, end, err := resolvePointer(data, nil, cfg)
+		if err != nil {
+			return nil, err
+		}
+		return splice(data, loc, end, value), nil
+	}
+	c, err := resolveParent(data, tokens, cfg)
+	if err != nil {
+		return nil, err
+	}
+	token := tokens[len(tokens)-1]
+	i := c.find(data, token)
+	if c.typ == BEGIN_OBJECT {
+		if i >= 0 {
+			return splice(data, c.members[i].loc, c.members[i].end, value), nil
+		}
+		at, ins := c.insertAt(data, len(c.members), c.appendMember(nil, data, token, value))
+		return splice(data, at, at, ins), nil
+	}
+	switch {
+	case token == "-":
+		i = len(c.members)
+	case i < 0 && newTokenSelector(token).index == indexSelector(len(c.members)):
+		i = len(c.members)
+	case i < 0:
+		return nil, fmt.Errorf("%w: index %s out of range", ErrPathNotFound, token)
+	}
+	at, ins := c.insertAt(data, i, value)
+	return splice(data, at, at, ins), nil
+}
+
+func patchRemove(data []byte, tokens []string, cfg *config) ([]byte, error) {
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("%w: can't remove the root", ErrInvalidPatch)
+	}
+	c, err := resolveParent(data, tokens, cfg)
+	if err != nil {
+		return nil, err
+	}
+	i := c.find(data, tokens[len(tokens)-1])
+	if i < 0 {
+		return nil, ErrPathNotFound
+	}
+	start, end := c.removeSpan(i)
+	return splice(data, start, end, nil), nil
+}
+
+// resolvePointer returns the location and end of the value referenced by
+// the reference tokens.
+func resolvePointer(data []byte, tokens []string, cfg *config) (loc, end int, err error) {
+	var iter Iterator
+	iter.cfg = *cfg
+	iter.Reset(data)
+	if len(tokens) == 0 {
+		_, loc, length := iter.Skip()
+		return loc, loc + length, iter.Error
+	}
+	c, err := resolveParent(data, tokens, cfg)
+	if err != nil {
+		return 0, 0, err
+	}
+	i := c.find(data, tokens[len(tokens)-1])
+	if i < 0 {
+		return 0, 0, ErrPathNotFound
+	}
+	return c.members[i].loc, c.members[i].end, nil
+}
+
+// resolveParent returns the container holding the value referenced by the
+// reference tokens, which must not be empty.
+func resolveParent(data []byte, tokens []string, cfg *config) (c container, err error) {
+	loc := skip(data, 0)
+	for i := 0; ; i++ {
+		if loc >= len(data) || typMap[data[loc]] != BEGIN_OBJECT && typMap[data[loc]] != BEGIN_ARRAY {
+			if loc >= len(data) || typMap[data[loc]] != INVALID {
+				err = ErrPathNotFound
+			} else {
+				_, err = readContainer(data, loc, cfg)
+			}
+			return c, err
+		}
+		if c, err = readContainer(data, loc, cfg); err != nil || i == len(tokens)-1 {
+			return c, err
+		}
+		j := c.find(data, tokens[i])
+		if j < 0 {
+			return c, ErrPathNotFound
+		}
+		loc = c.members[j].loc
+	}
+}
+
+// find returns the index of the member referenced by a reference token, or
+// -1 if there's none.
+func (c *container) find(data []byte, token string) int {
+	if c.typ == BEGIN_ARRAY {
+		if idx := int(newTokenSelector(token).index); idx < len(c.members) {
+			return idx
+		}
+		return -1
+	}
+	for i, m := range c.members {
+		if m.key(data).EqualString(token) {
+			return i
+		}
+	}
+	return -1
+}
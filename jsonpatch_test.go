@@ -0,0 +1,131 @@
+package jsontk
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestApplyPatch(t *testing.T) {
+	// examples in appendix A of RFC 6902
+	for _, tc := range []struct {
+		name, doc, patch, want string
+		err                    error
+	}{
+		{"AddObjectMember", `{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`, nil},
+		{"AddArrayElement", `{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`, nil},
+		{"RemoveObjectMember", `{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`, nil},
+		{"RemoveArrayElement", `{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`, nil},
+		{"Replace", `{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`, nil},
+		{"MoveValue", `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`, `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`, `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`, nil},
+		{"MoveArrayElement", `{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, `{"foo":["all","cows","eat","grass"]}`, nil},
+		{"Test", `{"baz":"qux","foo":["a",2,"c"]}`, `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`, `{"baz":"qux","foo":["a",2,"c"]}`, nil},
+		{"TestError", `{"baz":"qux"}`, `[{"op":"test","path":"/baz","value":"bar"}]`, ``, ErrTestFailed},
+		{"AddNestedMember", `{"foo":"bar"}`, `[{"op":"add","path":"/child","value":{"grandchild":{}}}]`, `{"foo":"bar","child":{"grandchild":{}}}`, nil},
+		{"IgnoreUnrecognized", `{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux","xyz":123}]`, `{"foo":"bar","baz":"qux"}`, nil},
+		{"AddToNonexistentTarget", `{"foo":"bar"}`, `[{"op":"add","path":"/baz/bat","value":"qux"}]`, ``, ErrPathNotFound},
+		{"EscapeOrdering", `{"/":9,"~1":10}`, `[{"op":"test","path":"/~01","value":10}]`, `{"/":9,"~1":10}`, nil},
+		{"StringsAndNumbers", `{"/":9,"~1":10}`, `[{"op":"test","path":"/~01","value":"10"}]`, ``, ErrTestFailed},
+		{"AddArrayValue", `{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`, `{"foo":["bar",["abc","def"]]}`, nil},
+
+		{"Copy", `{"a":{"b":[1]}}`, `[{"op":"copy","from":"/a/b","path":"/a/c"}]`, `{"a":{"b":[1],"c":[1]}}`, nil},
+		{"ReplaceRoot", `{"a":1}`, `[{"op":"replace","path":"","value":[1]}]`, `[1]`, nil},
+		{"AddIndexOutOfRange", `[1]`, `[{"op":"add","path":"/2","value":2}]`, ``, ErrPathNotFound},
+		{"AddAtEnd", `[1]`, `[{"op":"add","path":"/1","value":2}]`, `[1,2]`, nil},
+		{"MoveIntoChild", `{"a":{"b":1}}`, `[{"op":"move","from":"/a","path":"/a/b/c"}]`, ``, ErrInvalidPatch},
+		{"RemoveRoot", `{}`, `[{"op":"remove","path":""}]`, ``, ErrInvalidPatch},
+		{"UnknownOp", `{}`, `[{"op":"frob","path":""}]`, ``, ErrInvalidPatch},
+		{"MissingValue", `{}`, `[{"op":"add","path":"/a"}]`, ``, ErrInvalidPatch},
+		{"InvalidValue", `{}`, `[{"op":"add","path":"/a","value":tru}]`, ``, ErrInvalidPatch},
+		{"InvalidPointer", `{}`, `[{"op":"add","path":"a","value":1}]`, ``, ErrInvalidPointer},
+		{"ScalarParent", `{"a":1}`, `[{"op":"add","path":"/a/b","value":1}]`, ``, ErrPathNotFound},
+	} {
+		got, err := ApplyPatch([]byte(tc.doc), []byte(tc.patch))
+		if tc.err != nil {
+			var pe *PatchError
+			if !errors.Is(err, tc.err) || !errors.As(err, &pe) {
+				t.Errorf("%s: expected %v, got %v", tc.name, tc.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		var g, w interface{}
+		if err := json.Unmarshal(got, &g); err != nil {
+			t.Errorf("%s: invalid result %s", tc.name, got)
+		}
+		json.Unmarshal([]byte(tc.want), &w)
+		if !reflect.DeepEqual(g, w) {
+			t.Errorf("%s: got %s, want %s", tc.name, got, tc.want)
+		}
+	}
+
+	// members following one which isn't a string are still read
+	_, err := ApplyPatch([]byte(`{}`), []byte(`[{"path": 1, "op": "add", "value": 1}]`))
+	var se *SyntaxError
+	if !errors.Is(err, ErrInvalidPatch) || errors.As(err, &se) || !strings.Contains(err.Error(), "members must be strings") {
+		t.Errorf("expected members must be strings, got %v", err)
+	}
+
+	// the syntax error stays reachable along with ErrInvalidPatch
+	for _, tc := range []struct {
+		patch  string
+		err    error
+		offset int
+		opts   []Option
+	}{
+		{`[{"op":"add","path":"/a","value":tru}]`, ErrUnexpectedToken, 33, nil},
+		{`[{"op":"add","path":"/a","value":1},]`, ErrUnexpectedSep, 36, []Option{Strict()}},
+		{`[{"op":"add","path":"/a","value":[[1]]}]`, ErrLimitExceeded, 34, []Option{WithLimits(Limits{MaxDepth: 3})}},
+	} {
+		var se *SyntaxError
+		_, err := ApplyPatch([]byte(`{}`), []byte(tc.patch), tc.opts...)
+		if !errors.Is(err, ErrInvalidPatch) || !errors.Is(err, tc.err) || !errors.As(err, &se) || se.Offset != tc.offset {
+			t.Errorf("%s: expected %v at %d, got %v", tc.patch, tc.err, tc.offset, err)
+		}
+	}
+}
+
+func TestApplyPatchFormatting(t *testing.T) {
+	doc := `{
+	"a": 1.50,
+	"b": [ 1, 2 ],
+	"c": {"d": "A"}
+}`
+	for patch, want := range map[string]string{
+		`[{"op":"add","path":"/e","value":true}]`: `{
+	"a": 1.50,
+	"b": [ 1, 2 ],
+	"c": {"d": "A"},
+	"e": true
+}`,
+		`[{"op":"remove","path":"/a"}, {"op":"add","path":"/b/0","value":0}]`: `{
+	"b": [ 0, 1, 2 ],
+	"c": {"d": "A"}
+}`,
+		`[{"op":"remove","path":"/c"}, {"op":"remove","path":"/b/1"}]`: `{
+	"a": 1.50,
+	"b": [ 1 ]
+}`,
+		`[{"op":"add","path":"/c/e","value":{ }}]`: `{
+	"a": 1.50,
+	"b": [ 1, 2 ],
+	"c": {"d": "A", "e": { }}
+}`,
+	} {
+		got, err := ApplyPatch([]byte(doc), []byte(patch))
+		if err != nil || string(got) != want {
+			t.Errorf("%s: got %s, %v, want %s", patch, got, err, want)
+		}
+	}
+
+	var pe *PatchError
+	_, err := ApplyPatch([]byte(doc), []byte(`[{"op":"test","path":"/a","value":1.5},{"op":"remove","path":"/x"}]`))
+	if !errors.As(err, &pe) || pe.Index != 1 || pe.Op != "remove" || pe.Path != "/x" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
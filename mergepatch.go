@@ -0,0 +1,95 @@
+package jsontk
+
+// MergePatch applies a JSON Merge Patch (RFC 7386) to target. Members of
+// target objects are kept in their order with their original bytes unless
+// they're patched, members set to null by the patch are deleted, and new
+// members are appended to the end of objects. target is never modified.
+// Both target and patch are read with the options.
+func MergePatch(target, patch []byte, opts ...Option) ([]byte, error) {
+	var iter Iterator
+	iter.Configure(opts...)
+	iter.Reset(target)
+	_, tloc, tlen := iter.Skip()
+	if err := iter.Error; err != nil {
+		return nil, err
+	}
+	iter.Reset(patch)
+	_, ploc, plen := iter.Skip()
+	if iter.Error == nil {
+		if iter.skipSpace(); iter.head < len(patch) {
+			iter.fail(ErrUnexpectedToken, iter.head, false, "expected EOF")
+		}
+	}
+	if err := iter.Error; err != nil {
+		return nil, err
+	}
+	ret := make([]byte, 0, len(target)+len(patch))
+	ret = append(ret, target[:tloc]...)
+	ret, err := mergeValue(ret, target[tloc:tloc+tlen], patch[ploc:ploc+plen], &iter.cfg)
+	if err != nil {
+		return nil, err
+	}
+	return append(ret, target[tloc+tlen:]...), nil
+}
+
+// mergeValue appends the result of merging patch into target to dst. target
+// is nil if the member to be patched doesn't exist.
+func mergeValue(dst, target, patch []byte, cfg *config) ([]byte, error) {
+	if typMap[patch[0]] != BEGIN_OBJECT {
+		return append(dst, patch...), nil
+	}
+	p, err := readContainer(patch, 0, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if target == nil || typMap[target[0]] != BEGIN_OBJECT {
+		target = []byte{'{', '}'}
+	}
+	t, err := readContainer(target, 0, cfg)
+	if err != nil {
+		return nil, err
+	}
+	patched := make(map[string]int, len(p.members)) // unquoted key => patch member
+	for i, m := range p.members {
+		patched[m.key(patch).String()] = i
+	}
+
+	dst = append(dst, '{')
+	written := false
+	for i, m := range t.members {
+		key := m.key(target).String()
+		pi, ok := patched[key]
+		if !ok {
+			dst = append(append(dst, t.sep(target, i, written)...), target[m.start:m.end]...)
+			written = true
+			continue
+		}
+		delete(patched, key)
+		pm := p.members[pi]
+		if typMap[patch[pm.loc]] == NULL {
+			continue
+		}
+		dst = append(append(dst, t.sep(target, i, written)...), target[m.start:m.loc]...)
+		if dst, err = mergeValue(dst, target[m.loc:m.end], patch[pm.loc:pm.end], cfg); err != nil {
+			return nil, err
+		}
+		written = true
+	}
+	for i, m := range p.members {
+		if pi, ok := patched[m.key(patch).String()]; !ok || pi != i || typMap[patch[m.loc]] == NULL {
+			continue // merged already, or not the last duplicate
+		}
+		dst = append(dst, t.sep(target, len(t.members), written)...)
+		dst = t.appendMember(dst, target, m.key(patch).String(), nil)
+		if dst, err = mergeValue(dst, nil, patch[m.loc:m.end], cfg); err != nil {
+			return nil, err
+		}
+		written = true
+	}
+	if len(t.members) != 0 {
+		dst = append(dst, target[t.members[len(t.members)-1].end:t.end-1]...)
+	} else if !written {
+		dst = append(dst, target[t.open+1:t.end-1]...)
+	}
+	return append(dst, '}'), nil
+}
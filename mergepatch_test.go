@@ -0,0 +1,78 @@
+package jsontk
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMergePatch(t *testing.T) {
+	// examples in appendix A of RFC 7386
+	for _, tc := range []struct{ target, patch, want string }{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a": "b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a": {"bb": {}}}`},
+	} {
+		got, err := MergePatch([]byte(tc.target), []byte(tc.patch))
+		if err != nil || string(got) != tc.want {
+			t.Errorf("%s + %s: got %s, %v, want %s", tc.target, tc.patch, got, err, tc.want)
+		}
+	}
+}
+
+func TestMergePatchFormatting(t *testing.T) {
+	target := ` {
+	"title": "Goodbye!",
+	"author" : {
+		"givenName" : "John",
+		"familyName" : "Doe"
+	},
+	"tags":[ "example", "sample" ],
+	"content": "This will be unchanged",
+	"price": 1.50e0
+}
+`
+	patch := `{
+	"title": "Hello!",
+	"phoneNumber": "+01-123-456-7890",
+	"author": {"familyName": null},
+	"tags": [ "example" ]
+}`
+	want := ` {
+	"title": "Hello!",
+	"author" : {
+		"givenName" : "John"
+	},
+	"tags":[ "example" ],
+	"content": "This will be unchanged",
+	"price": 1.50e0,
+	"phoneNumber": "+01-123-456-7890"
+}
+`
+	got, err := MergePatch([]byte(target), []byte(patch))
+	if err != nil || string(got) != want {
+		t.Errorf("got %s, %v, want %s", got, err, want)
+	}
+
+	got, err = MergePatch([]byte(`{"a": 1, "b": 2, "c": 3}`), []byte(`{"a": null, "c": null, "d": 4}`))
+	if err != nil || string(got) != `{"b": 2, "d": 4}` {
+		t.Errorf("got %s, %v", got, err)
+	}
+
+	for _, tc := range [][2]string{{`{"a": }`, `{}`}, {`{}`, `{"a": 1`}, {`{}`, `{} 1`}} {
+		if _, err := MergePatch([]byte(tc[0]), []byte(tc[1])); err == nil || !errors.As(err, new(*SyntaxError)) {
+			t.Errorf("%s + %s: expected syntax error, got %v", tc[0], tc[1], err)
+		}
+	}
+}
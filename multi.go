@@ -0,0 +1,242 @@
+package jsontk
+
+// PathSet is a set of compiled JSONPaths merged into a trie, so that they
+// could be selected in a single pass, see [Iterator.SelectMulti]. It's
+// immutable and safe for concurrent use.
+type PathSet struct {
+	trie *pathTrie
+	root bool // filters in some path refer to the root
+}
+
+// pathTrie is a node of the trie. Paths sharing a prefix of selectors
+// share the nodes for it, and a descendant segment (..) together with the
+// selector following it is a single edge.
+type pathTrie struct {
+	ends  []int // indexes of the paths ending at this node
+	edges []trieEdge
+	desc  *pathTrie // the descendant edges only, kept active in children
+}
+
+type trieEdge struct {
+	desc bool
+	sel  selector
+	norm string // normalized form of sel, for merging
+	next *pathTrie
+}
+
+// NewPathSet merges the paths into a [PathSet]. Matches are reported with
+// the index of the path in paths.
+func NewPathSet(paths ...*Path) *PathSet {
+	set := &PathSet{trie: new(pathTrie)}
+	for i, p := range paths {
+		node, desc := set.trie, false
+		for _, s := range p.sel {
+			if s == recursive {
+				desc = true
+				continue
+			}
+			node, desc = node.edge(desc, s), false
+		}
+		node.ends = append(node.ends, i)
+		set.root = set.root || p.root
+	}
+	set.trie.link()
+	return set
+}
+
+func (t *pathTrie) edge(desc bool, s selector) *pathTrie {
+	norm := string(s.appendTo(nil))
+	for _, e := range t.edges {
+		if e.desc == desc && e.norm == norm {
+			return e.next
+		}
+	}
+	next := new(pathTrie)
+	t.edges = append(t.edges, trieEdge{desc: desc, sel: s, norm: norm, next: next})
+	return next
+}
+
+// link sets up the desc node of t and its children.
+func (t *pathTrie) link() {
+	for _, e := range t.edges {
+		e.next.link()
+		if e.desc {
+			if t.desc == nil {
+				t.desc = new(pathTrie)
+				t.desc.desc = t.desc
+			}
+			t.desc.edges = append(t.desc.edges, e)
+		}
+	}
+}
+
+// SelectMulti walks the next value once, calling cb for each value matched
+// by the paths in set, with the index of the path that matched it. Values are
+// reported in document order rather than in the order of RFC 9535, and a
+// value matched more than once by the same path is reported once.
+// As with [Iterator.Select], cb MUST consume the value.
+func (iter *Iterator) SelectMulti(set *PathSet, cb func(idx int, iter *Iterator)) error {
+	if set.root {
+		defer iter.withRoot()()
+	}
+	traverseTrie(iter, []*pathTrie{set.trie}, cb)
+	return iter.Error
+}
+
+// traverseTrie visits the next value with the trie nodes active at it.
+func traverseTrie(iter *Iterator, nodes []*pathTrie, f func(idx int, iter *Iterator)) {
+	var ends []int
+	descend := false
+	for _, n := range nodes {
+		ends = append(ends, n.ends...)
+		descend = descend || len(n.edges) != 0
+	}
+	if len(ends) != 0 {
+		iter.skipSpace()
+		save := iter.head
+		iter.pin++
+		for i, idx := range ends {
+			if i != 0 {
+				iter.head = save
+			}
+			f(idx, iter)
+			if iter.Error != nil {
+				iter.pin--
+				return
+			}
+		}
+		iter.pin--
+		if !descend {
+			return
+		}
+		iter.head = save
+	}
+	if !descend {
+		iter.discard()
+		return
+	}
+	switch iter.Peek() {
+	case BEGIN_OBJECT:
+		iter.NextObject(func(key *Token) bool {
+			k := *key // filters may read other keys
+			next := childNodes(nodes, func(s selector) bool { return s.SelectObj(&k, iter) })
+			if len(next) == 0 {
+				iter.discard()
+			} else {
+				traverseTrie(iter, next, f)
+			}
+			return true
+		})
+	case BEGIN_ARRAY:
+		if !needsLen(nodes) {
+			iter.NextArray(func(idx int) bool {
+				next := childNodes(nodes, func(s selector) bool { return s.SelectArr(idx, iter) })
+				if len(next) == 0 {
+					iter.discard()
+				} else {
+					traverseTrie(iter, next, f)
+				}
+				return true
+			})
+			return
+		}
+		// negative indexes are resolved with the length of the array
+		indexes := make([]int, 0, 10)
+		iter.pin++
+		defer func() { iter.pin-- }()
+		if iter.NextArray(func(idx int) bool {
+			_, i, _ := iter.Skip()
+			indexes = append(indexes, i)
+			return true
+		}) != nil {
+			return
+		}
+		after := iter.head
+		for idx, i := range indexes {
+			iter.head = i
+			next := childNodes(nodes, func(s selector) bool { return selectIndex(s, idx, len(indexes), iter) })
+			if len(next) != 0 {
+				traverseTrie(iter, next, f)
+				if iter.Error != nil {
+					return
+				}
+			}
+		}
+		iter.head = after
+	default:
+		iter.discard()
+	}
+}
+
+// childNodes returns the trie nodes active at a child, given the nodes
+// active at its parent and whether a selector selects the child.
+func childNodes(nodes []*pathTrie, selects func(s selector) bool) (next []*pathTrie) {
+	add := func(n *pathTrie) {
+		for _, m := range next {
+			if m == n {
+				return
+			}
+		}
+		next = append(next, n)
+	}
+	for _, n := range nodes {
+		for _, e := range n.edges {
+			if selects(e.sel) {
+				add(e.next)
+			}
+		}
+		if n.desc != nil {
+			add(n.desc)
+		}
+	}
+	return next
+}
+
+// needsLen reports whether any selector on the edges needs the length of
+// the array to select its elements.
+func needsLen(nodes []*pathTrie) bool {
+	for _, n := range nodes {
+		for _, e := range n.edges {
+			if selectorNeedsLen(e.sel) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func selectorNeedsLen(s selector) bool {
+	switch s := s.(type) {
+	case indexSelector:
+		return s < 0
+	case *arrSliceSelector:
+		return s.needsLen()
+	case combineSelector:
+		for _, s := range s {
+			if selectorNeedsLen(s) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// selectIndex is like SelectArr, with the length n of the array known.
+func selectIndex(s selector, idx, n int, iter *Iterator) bool {
+	switch s := s.(type) {
+	case indexSelector:
+		if s < 0 {
+			return idx == n+int(s)
+		}
+	case *arrSliceSelector:
+		return s.contains(idx, n)
+	case combineSelector:
+		for _, s := range s {
+			if selectIndex(s, idx, n, iter) {
+				return true
+			}
+		}
+		return false
+	}
+	return s.SelectArr(idx, iter)
+}
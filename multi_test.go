@@ -0,0 +1,86 @@
+package jsontk
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestSelectMulti(t *testing.T) {
+	data := []byte(`{
+	"a": {"b": 1, "c": [1, 2, 3]},
+	"b": [{"b": true}, {"c": null}],
+	"c": [3, 4, 5, 6],
+	"x": 2
+}`)
+	paths := []string{
+		`$.a`,
+		`$.a.b`,
+		`$.a.c[0]`,
+		`$..b`,
+		`$.c[-1]`,
+		`$.c[::-2]`,
+		`$.c[?@ > $.x]`,
+		`$..c[1]`,
+		`$..*`,
+		`$..b`,
+		`$`,
+		`$.nothing`,
+	}
+	var compiled []*Path
+	for _, path := range paths {
+		compiled = append(compiled, MustCompilePath(path))
+	}
+	set := NewPathSet(compiled...)
+
+	// each path matches the same values as Select, in document order
+	want := make([][]string, len(paths))
+	var iter Iterator
+	for i, p := range compiled {
+		var locs []int
+		iter.Reset(data)
+		iter.SelectCompiled(p, func(iter *Iterator) {
+			_, loc, _ := iter.Skip()
+			locs = append(locs, loc)
+		})
+		sort.Ints(locs)
+		for j, loc := range locs {
+			if j == 0 || loc != locs[j-1] {
+				iter.Reset(data[loc:])
+				_, _, l := iter.Skip()
+				want[i] = append(want[i], string(data[loc:loc+l]))
+			}
+		}
+	}
+	check := func(t *testing.T, iter *Iterator) {
+		got := make([][]string, len(paths))
+		if err := iter.SelectMulti(set, func(idx int, iter *Iterator) {
+			_, i, l := iter.Skip()
+			got[idx] = append(got[idx], string(iter.data[i:i+l]))
+		}); err != nil {
+			t.Fatal(err)
+		}
+		for i := range paths {
+			if strings.Join(got[i], "|") != strings.Join(want[i], "|") {
+				t.Errorf("%s: got %q, want %q", paths[i], got[i], want[i])
+			}
+		}
+	}
+	t.Run("Bytes", func(t *testing.T) {
+		iter.Reset(data)
+		check(t, &iter)
+	})
+	t.Run("Reader", func(t *testing.T) {
+		iter.ResetReader(iotest.OneByteReader(bytes.NewReader(data)))
+		check(t, &iter)
+	})
+	t.Run("Error", func(t *testing.T) {
+		iter.Reset([]byte(`{"a": [1, 2,, 3]}`))
+		err := iter.SelectMulti(set, func(idx int, iter *Iterator) { iter.Skip() })
+		if err == nil || !strings.Contains(err.Error(), "in $['a']") {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+}
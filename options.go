@@ -0,0 +1,126 @@
+package jsontk
+
+import (
+	"math"
+	"strconv"
+)
+
+// Option configures how json is read, see [Iterator.Configure] and [Iterate].
+type Option func(*config)
+
+type config struct {
+	strict     bool
+	utf8       UTF8Mode
+	uniqueKeys bool
+	limits     Limits
+
+	validateOutput bool // see ValidateOutput
+
+	checked bool // whether tokens need checking after being tokenized
+}
+
+func (c *config) apply(opts []Option) {
+	for _, opt := range opts {
+		opt(c)
+	}
+	l := &c.limits
+	c.checked = c.strict || c.utf8 == UTF8Reject || l.MaxStringLength > 0 || l.MaxNumberLength > 0 || l.MaxTokens > 0
+}
+
+// maxDepth returns the effective MaxDepth.
+func (c *config) maxDepth() int {
+	switch d := c.limits.MaxDepth; {
+	case d == 0:
+		return DefaultMaxDepth
+	case d < 0:
+		return math.MaxInt
+	default:
+		return d
+	}
+}
+
+// check checks the token at i, as returned by next, against the options.
+func (c *config) check(s []byte, i int, typ TokenType, length int) error {
+	bad, msg := -1, ""
+	switch {
+	case typ == STRING && c.limits.MaxStringLength > 0 && length > c.limits.MaxStringLength:
+		return c.limits.exceeded(i, "string longer than", c.limits.MaxStringLength)
+	case typ == NUMBER && c.limits.MaxNumberLength > 0 && length > c.limits.MaxNumberLength:
+		return c.limits.exceeded(i, "number longer than", c.limits.MaxNumberLength)
+	case typ == STRING:
+		bad, msg = checkString(s[i:i+length], c.strict, c.utf8 == UTF8Reject)
+	case typ == NUMBER && c.strict:
+		bad, msg = checkNumber(s[i:i+length]), "invalid number"
+	}
+	if bad < 0 {
+		return nil
+	}
+	return newSyntaxError(ErrStandardViolation, i+bad, msg)
+}
+
+// Strict makes the input strictly conform to RFC 8259. Trailing commas,
+// malformed numbers like 01, 1. or 1e, control characters in strings and
+// invalid escape sequences are rejected when tokenized. By default, they're
+// accepted for speed.
+func Strict() Option {
+	return func(c *config) { c.strict = true }
+}
+
+// UTF8Mode tells how invalid UTF-8 in strings is handled, see [ValidUTF8].
+type UTF8Mode uint8
+
+const (
+	// UTF8PassThrough keeps invalid UTF-8 bytes as is, which is the default.
+	UTF8PassThrough UTF8Mode = iota
+	// UTF8Reject rejects strings containing invalid UTF-8 or escaped lone
+	// surrogates when tokenized.
+	UTF8Reject
+	// UTF8Replace replaces each byte of invalid UTF-8 sequences with U+FFFD
+	// when strings are unquoted.
+	UTF8Replace
+)
+
+// ValidUTF8 sets how invalid UTF-8 in strings and object keys is handled.
+// Escaped lone surrogates are always unquoted as U+FFFD, unless rejected.
+func ValidUTF8(mode UTF8Mode) Option {
+	return func(c *config) { c.utf8 = mode }
+}
+
+// DisallowDuplicateKeys makes objects with duplicate keys, compared in their
+// unquoted forms, rejected with [ErrDuplicateKey] at the location of the
+// duplicate. It applies to [Iterator] and [Validate], but not [Iterate].
+func DisallowDuplicateKeys() Option {
+	return func(c *config) { c.uniqueKeys = true }
+}
+
+// DefaultMaxDepth is the maximum nesting depth of objects and arrays when
+// Limits.MaxDepth is zero, which is also the case without [WithLimits].
+const DefaultMaxDepth = 10000
+
+// Limits bounds the resources spent reading untrusted input. Zero fields
+// mean no limit, except MaxDepth, which is [DefaultMaxDepth] when zero and
+// unlimited when negative. Violations are reported with [ErrLimitExceeded].
+type Limits struct {
+	MaxDepth        int // nesting depth of objects and arrays
+	MaxStringLength int // bytes of a string or object key, including the quotes
+	MaxNumberLength int // bytes of a number
+	MaxMembers      int // members of an object or elements of an array
+	MaxTokens       int // values and object keys in total
+}
+
+func (l *Limits) exceeded(pos int, what string, limit int) *SyntaxError {
+	return newSyntaxError(ErrLimitExceeded, pos, what+" "+strconv.Itoa(limit))
+}
+
+// WithLimits sets the limits, see [Limits]. They apply to [Iterator],
+// [Iterate] and [Validate], to the queries run by filters in [Iterator.Select],
+// and to the functions patching data such as [Patch] and [ApplyPatch].
+func WithLimits(l Limits) Option {
+	return func(c *config) { c.limits = l }
+}
+
+// Configure applies opts to the iterator, which are kept across Reset and
+// ResetReader.
+func (iter *Iterator) Configure(opts ...Option) {
+	iter.cfg.apply(opts)
+}
@@ -2,42 +2,264 @@ package jsontk
 
 import (
 	"fmt"
+	"io"
+	"sort"
 )
 
+// PatchOption configures [Patch]. Any [Option] is one too, and applies to
+// how data is read, e.g. [WithLimits] for untrusted input.
+type PatchOption = Option
+
+// ValidateOutput makes [Patch] check that every output of the callback is a
+// single json value strictly conforming to RFC 8259, failing otherwise. It
+// has no effect elsewhere.
+func ValidateOutput() PatchOption {
+	return func(c *config) { c.validateOutput = true }
+}
+
+// Patch replaces each value selected by the JSONPath with the output of f,
+// which receives the raw json of the value. It returns the result and the
+// number of values replaced. If the path is invalid, or data is malformed
+// where it's read, an error is returned, which is a *[SyntaxError] with the
+// offset in data for the latter.
+//
 // Patch API is currently unstable
-func Patch(data []byte, path string, f func([]byte) []byte) ([]byte, int) {
-	type replaceOp struct {
-		start, length int
+func Patch(data []byte, path string, f func([]byte) []byte, opts ...PatchOption) ([]byte, int, error) {
+	p, err := CompilePath(path)
+	if err != nil {
+		return data, 0, err
 	}
-	var replaces []replaceOp
-	iter := Iterator{}
-	iter.Reset(data)
+	return PatchCompiled(data, p, f, opts...)
+}
+
+// PatchCompiled is like [Patch] but takes a compiled path.
+func PatchCompiled(data []byte, path *Path, f func([]byte) []byte, opts ...PatchOption) ([]byte, int, error) {
+	ret, n, err := AppendPatchCompiled(make([]byte, 0, len(data)), data, path, f, opts...)
+	if err != nil {
+		return data, 0, err
+	}
+	return ret, n, nil
+}
+
+// AppendPatch is like [Patch], but appends the result to dst. data is left
+// untouched, and the result is written in a single pass over it. If an
+// error occurs, dst is returned unchanged.
+func AppendPatch(dst, data []byte, path string, f func([]byte) []byte, opts ...PatchOption) ([]byte, int, error) {
+	p, err := CompilePath(path)
+	if err != nil {
+		return dst, 0, err
+	}
+	return AppendPatchCompiled(dst, data, p, f, opts...)
+}
 
-	err := iter.Select(path, func(iter *Iterator) {
-		var loc, length int
-		switch iter.Peek() {
-		case BEGIN_ARRAY, BEGIN_OBJECT:
-			_, loc, length = iter.Skip()
-		case INVALID, END_ARRAY, END_OBJECT:
-			iter.Error = fmt.Errorf("%w at %d", ErrUnexpectedToken, iter.head)
-			return
-		default:
-			_, loc, length = iter.Next()
-		}
-		replaces = append(replaces, replaceOp{loc, length})
+// AppendPatchCompiled is like [AppendPatch] but takes a compiled path.
+func AppendPatchCompiled(dst, data []byte, path *Path, f func([]byte) []byte, opts ...PatchOption) ([]byte, int, error) {
+	ret := dst
+	n, err := patch(data, path, f, opts, func(b []byte) error {
+		ret = append(ret, b...)
+		return nil
 	})
-	if err == nil && iter.Error == ErrInterrupt {
-		err = fmt.Errorf("%w at %d", ErrUnexpectedToken, iter.head)
+	if err != nil {
+		return dst, 0, err
 	}
+	return ret, n, nil
+}
+
+// WritePatch is like [Patch], but writes the result to w. data is left
+// untouched, and the result is written in a single pass over it. If an
+// error occurs, part of the result may have been written to w.
+func WritePatch(w io.Writer, data []byte, path string, f func([]byte) []byte, opts ...PatchOption) (int, error) {
+	p, err := CompilePath(path)
 	if err != nil {
-		// TODO: handle errors
-		return data, 0
+		return 0, err
+	}
+	return WritePatchCompiled(w, data, p, f, opts...)
+}
+
+// WritePatchCompiled is like [WritePatch] but takes a compiled path.
+func WritePatchCompiled(w io.Writer, data []byte, path *Path, f func([]byte) []byte, opts ...PatchOption) (int, error) {
+	return patch(data, path, f, opts, func(b []byte) error {
+		_, err := w.Write(b)
+		return err
+	})
+}
+
+// patch emits the patched data piece by piece. Selected values are replaced
+// in the order they appear in data, and values nested in another selected
+// value are replaced along with it, without calling f on them.
+func patch(data []byte, path *Path, f func([]byte) []byte, opts []PatchOption, emit func([]byte) error) (int, error) {
+	var iter Iterator
+	iter.Configure(opts...)
+	iter.Reset(data)
+	var replaces []edit
+	if err := iter.SelectCompiled(path, func(iter *Iterator) {
+		_, loc, length := iter.Skip()
+		replaces = append(replaces, edit{start: loc, end: loc + length})
+	}); err != nil {
+		return 0, err
+	}
+	sort.Slice(replaces, func(i, j int) bool { return replaces[i].start < replaces[j].start })
+
+	n, last := 0, 0
+	for _, r := range replaces {
+		if r.start < last {
+			continue // nested in or the same as the previous one
+		}
+		value := f(data[r.start:r.end:r.end]) // appending to it mustn't overwrite data
+		if iter.cfg.validateOutput {
+			if err := validateValue(value, &iter.cfg); err != nil {
+				return n, fmt.Errorf("replacement of the value at %d: %w", r.start, err)
+			}
+		}
+		if err := emit(data[last:r.start]); err != nil {
+			return n, err
+		}
+		if err := emit(value); err != nil {
+			return n, err
+		}
+		n, last = n+1, r.end
+	}
+	return n, emit(data[last:])
+}
+
+// validateValue checks that raw is a single json value strictly conforming
+// to RFC 8259, and within the limits of cfg.
+func validateValue(raw []byte, cfg *config) error {
+	var iter Iterator
+	iter.cfg = *cfg
+	iter.Reset(raw)
+	if iter.skipSpace(); iter.head == len(raw) {
+		return iter.fail(ErrEarlyEOF, iter.head, false, "expected a value")
+	}
+	return iter.Validate()
+}
+
+// Delete removes the values selected by the JSONPath, together with their
+// keys if they're object members and exactly one adjacent comma, so that the
+// result is still valid json. It returns the result and the number of values
+// removed, in which values nested in another removed one aren't counted.
+// data itself is never modified, and is read with the options.
+func Delete(data []byte, path string, opts ...Option) ([]byte, int, error) {
+	p, err := CompilePath(path)
+	if err != nil {
+		return data, 0, err
+	}
+	return DeleteCompiled(data, p, opts...)
+}
+
+// DeleteCompiled is like [Delete] but takes a compiled path.
+func DeleteCompiled(data []byte, path *Path, opts ...Option) ([]byte, int, error) {
+	var iter Iterator
+	iter.Configure(opts...)
+	iter.Reset(data)
+	var spans []edit
+	if err := iter.SelectCompiled(path, func(iter *Iterator) {
+		_, loc, length := iter.Skip()
+		spans = append(spans, edit{start: loc, end: loc + length})
+	}); err != nil {
+		return data, 0, err
+	}
+	if len(spans) == 0 {
+		return data, 0, nil
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+	var locs []int
+	last := 0
+	for _, s := range spans {
+		if s.start < last {
+			continue // nested in or the same as the previous one
+		}
+		locs, last = append(locs, s.start), s.end
+	}
+	n := len(locs)
+	root := skip(data, 0)
+	if locs[0] == root {
+		return data, 0, fmt.Errorf("%w: can't delete the root", ErrInvalidJsonpath)
+	}
+	edits, err := removeEdits(nil, data, root, locs, &iter.cfg)
+	if err != nil {
+		return data, 0, err
+	}
+	return applyEdits(make([]byte, 0, len(data)), data, edits), n, nil
+}
+
+// InsertMember inserts a member with the key and the raw json value into
+// every object selected by the JSONPath, regardless of existing members with
+// the same key. idx is the index of the inserted member in the resulting
+// object, in which negative indexes count from the end, so that 0 inserts at
+// the start and -1 at the end. Separators are formatted like those between
+// existing members. It returns the result and the number of objects changed.
+// data is read with the options.
+func InsertMember(data []byte, path string, idx int, key string, value []byte, opts ...Option) ([]byte, int, error) {
+	p, err := CompilePath(path)
+	if err != nil {
+		return data, 0, err
+	}
+	return InsertMemberCompiled(data, p, idx, key, value, opts...)
+}
+
+// InsertMemberCompiled is like [InsertMember] but takes a compiled path.
+func InsertMemberCompiled(data []byte, path *Path, idx int, key string, value []byte, opts ...Option) ([]byte, int, error) {
+	return insert(data, path, BEGIN_OBJECT, idx, func(c *container) []byte {
+		return c.appendMember(nil, data, key, value)
+	}, opts)
+}
+
+// InsertElement inserts the raw json value into every array selected by the
+// JSONPath. idx is the index of the inserted element in the resulting array,
+// in which negative indexes count from the end, so that 1 inserts after the
+// first element and -1 appends to the array. Arrays too short for idx are
+// left unchanged. It returns the result and the number of arrays changed.
+// data is read with the options.
+func InsertElement(data []byte, path string, idx int, value []byte, opts ...Option) ([]byte, int, error) {
+	p, err := CompilePath(path)
+	if err != nil {
+		return data, 0, err
+	}
+	return InsertElementCompiled(data, p, idx, value, opts...)
+}
+
+// InsertElementCompiled is like [InsertElement] but takes a compiled path.
+func InsertElementCompiled(data []byte, path *Path, idx int, value []byte, opts ...Option) ([]byte, int, error) {
+	return insert(data, path, BEGIN_ARRAY, idx, func(*container) []byte { return value }, opts)
+}
+
+func insert(data []byte, path *Path, typ TokenType, idx int, raw func(c *container) []byte, opts []Option) ([]byte, int, error) {
+	var iter Iterator
+	iter.Configure(opts...)
+	iter.Reset(data)
+	var locs []int
+	if err := iter.SelectCompiled(path, func(iter *Iterator) {
+		if iter.Peek() == typ {
+			locs = append(locs, iter.head)
+		}
+		iter.Skip()
+	}); err != nil {
+		return data, 0, err
+	}
+	sort.Ints(locs)
+	var edits []edit
+	for i, loc := range locs {
+		if i > 0 && loc == locs[i-1] {
+			continue
+		}
+		c, err := readContainer(data, loc, &iter.cfg)
+		if err != nil {
+			return data, 0, err
+		}
+		pos := idx
+		if pos < 0 {
+			pos += len(c.members) + 1
+		}
+		if pos < 0 || pos > len(c.members) {
+			continue
+		}
+		at, ins := c.insertAt(data, pos, raw(&c))
+		edits = append(edits, edit{start: at, end: at, repl: ins})
 	}
-	result := data
-	for i := len(replaces) - 1; i >= 0; i-- {
-		op := replaces[i]
-		newVal := f(data[op.start : op.start+op.length])
-		result = append(result[:op.start], append(newVal, result[op.start+op.length:]...)...)
+	if len(edits) == 0 {
+		return data, 0, nil
 	}
-	return result, len(replaces)
+	sort.SliceStable(edits, func(i, j int) bool { return edits[i].start < edits[j].start })
+	return applyEdits(make([]byte, 0, len(data)), data, edits), len(edits), nil
 }
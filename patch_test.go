@@ -2,79 +2,83 @@ package jsontk
 
 import (
 	"encoding/json"
+	"errors"
 	"strconv"
+	"strings"
 	"testing"
 )
 
 func TestPatch(t *testing.T) {
 	t.Run("ReplaceSimpleStringValue", func(t *testing.T) {
-		got, count := Patch([]byte(`{"name": "old"}`), "$.name", func([]byte) []byte {
+		got, count, err := Patch([]byte(`{"name": "old"}`), "$.name", func([]byte) []byte {
 			return []byte(`"new"`)
 		})
-		assertPatch(t, got, count, `{"name": "new"}`, 1)
+		assertPatch(t, got, count, err, `{"name": "new"}`, 1)
 	})
 
 	t.Run("ReplaceNumberValue", func(t *testing.T) {
-		got, count := Patch([]byte(`{"age": 20}`), "$.age", func([]byte) []byte {
+		got, count, err := Patch([]byte(`{"age": 20}`), "$.age", func([]byte) []byte {
 			return []byte("30")
 		})
-		assertPatch(t, got, count, `{"age": 30}`, 1)
+		assertPatch(t, got, count, err, `{"age": 30}`, 1)
 	})
 
 	t.Run("ReplaceNestedObjectValue", func(t *testing.T) {
-		got, count := Patch([]byte(`{"user": {"name": "old", "age": 20}}`), "$.user.name", func([]byte) []byte {
+		got, count, err := Patch([]byte(`{"user": {"name": "old", "age": 20}}`), "$.user.name", func([]byte) []byte {
 			return []byte(`"new"`)
 		})
-		assertPatch(t, got, count, `{"user": {"name": "new", "age": 20}}`, 1)
+		assertPatch(t, got, count, err, `{"user": {"name": "new", "age": 20}}`, 1)
 	})
 
 	t.Run("ReplaceWithLongerValue", func(t *testing.T) {
-		got, count := Patch([]byte(`{"name": "old"}`), "$.name", func([]byte) []byte {
+		got, count, err := Patch([]byte(`{"name": "old"}`), "$.name", func([]byte) []byte {
 			return []byte(`"very long new value"`)
 		})
-		assertPatch(t, got, count, `{"name": "very long new value"}`, 1)
+		assertPatch(t, got, count, err, `{"name": "very long new value"}`, 1)
 	})
 
 	t.Run("ReplaceWithShorterValue", func(t *testing.T) {
-		got, count := Patch([]byte(`{"name": "very long old value"}`), "$.name", func([]byte) []byte {
+		got, count, err := Patch([]byte(`{"name": "very long old value"}`), "$.name", func([]byte) []byte {
 			return []byte(`"new"`)
 		})
-		assertPatch(t, got, count, `{"name": "new"}`, 1)
+		assertPatch(t, got, count, err, `{"name": "new"}`, 1)
 	})
 
 	t.Run("DeepNestedPath", func(t *testing.T) {
-		got, count := Patch([]byte(`{"a": {"b": {"c": {"d": "old"}}}}`), "$.a.b.c.d", func([]byte) []byte {
+		got, count, err := Patch([]byte(`{"a": {"b": {"c": {"d": "old"}}}}`), "$.a.b.c.d", func([]byte) []byte {
 			return []byte(`"new"`)
 		})
-		assertPatch(t, got, count, `{"a": {"b": {"c": {"d": "new"}}}}`, 1)
+		assertPatch(t, got, count, err, `{"a": {"b": {"c": {"d": "new"}}}}`, 1)
 	})
 
 	t.Run("InvalidPath", func(t *testing.T) {
-		got, count := Patch([]byte(`{"name": "old"}`), "$.nonexistent", func([]byte) []byte {
+		got, count, err := Patch([]byte(`{"name": "old"}`), "$.nonexistent", func([]byte) []byte {
 			return []byte(`"new"`)
 		})
-		assertPatch(t, got, count, `{"name": "old"}`, 0)
+		assertPatch(t, got, count, err, `{"name": "old"}`, 0)
 	})
 
 	t.Run("InvalidJsonPathFormat", func(t *testing.T) {
-		got, count := Patch([]byte(`{"name": "old"}`), "invalid.path", func([]byte) []byte {
+		got, count, err := Patch([]byte(`{"name": "old"}`), "invalid.path", func([]byte) []byte {
 			return []byte(`"new"`)
 		})
-		assertPatch(t, got, count, `{"name": "old"}`, 0)
+		if !errors.Is(err, ErrInvalidJsonpath) || count != 0 || string(got) != `{"name": "old"}` {
+			t.Errorf("unexpected result %s, %d, %v", got, count, err)
+		}
 	})
 
 	t.Run("ReplaceMultipleValuesWithWildcard", func(t *testing.T) {
-		got, count := Patch([]byte(`[{"name": "old"}, {"name": "old"}]`), "$.*.name", func([]byte) []byte {
+		got, count, err := Patch([]byte(`[{"name": "old"}, {"name": "old"}]`), "$.*.name", func([]byte) []byte {
 			return []byte(`"new"`)
 		})
-		assertPatch(t, got, count, `[{"name": "new"}, {"name": "new"}]`, 2)
+		assertPatch(t, got, count, err, `[{"name": "new"}, {"name": "new"}]`, 2)
 	})
 
 	t.Run("ReplaceSpecificArrayElement", func(t *testing.T) {
-		got, count := Patch([]byte(`[{"name": "old"}, {"name": "old"}]`), "$[0].name", func([]byte) []byte {
+		got, count, err := Patch([]byte(`[{"name": "old"}, {"name": "old"}]`), "$[0].name", func([]byte) []byte {
 			return []byte(`"new"`)
 		})
-		assertPatch(t, got, count, `[{"name": "new"}, {"name": "old"}]`, 1)
+		assertPatch(t, got, count, err, `[{"name": "new"}, {"name": "old"}]`, 1)
 	})
 
 	t.Run("MultipleSequentialReplacements", func(t *testing.T) {
@@ -84,13 +88,48 @@ func TestPatch(t *testing.T) {
 
 		for _, path := range []string{"$.a", "$.b", "$.c"} {
 			var count int
-			result, count = Patch(result, path, func([]byte) []byte {
+			var err error
+			result, count, err = Patch(result, path, func([]byte) []byte {
 				return []byte(`"new"`)
 			})
+			if err != nil {
+				t.Fatal(err)
+			}
 			totalCount += count
 		}
 
-		assertPatch(t, result, totalCount, `{"a": "new", "b": "new", "c": "new"}`, 3)
+		assertPatch(t, result, totalCount, nil, `{"a": "new", "b": "new", "c": "new"}`, 3)
+	})
+	t.Run("Errors", func(t *testing.T) {
+		var se *SyntaxError
+		_, _, err := Patch([]byte(`{"a": [1, 2,, 3]}`), "$.a[*]", func(v []byte) []byte { return v })
+		if !errors.As(err, &se) || se.Offset != 12 {
+			t.Errorf("unexpected error %v", err)
+		}
+		data := []byte(`{"a": 1, "b": 2}`)
+		for _, out := range []string{``, `1 2`, `{"a":}`, `tru`, `[1,]`, `01`} {
+			_, _, err = Patch(data, "$.*", func(v []byte) []byte {
+				if v[0] == '2' {
+					return []byte(out)
+				}
+				return v
+			}, ValidateOutput())
+			if !errors.As(err, &se) || !strings.Contains(err.Error(), "value at 14") {
+				t.Errorf("%q: unexpected error %v", out, err)
+			}
+		}
+		if string(data) != `{"a": 1, "b": 2}` {
+			t.Errorf("input modified: %s", data)
+		}
+		got, count, err := Patch(data, "$.*", func(v []byte) []byte { return []byte(" [ 1 ] ") }, ValidateOutput())
+		assertPatch(t, got, count, err, `{"a":  [ 1 ] , "b":  [ 1 ] }`, 2)
+	})
+	t.Run("PatchCompiledPath", func(t *testing.T) {
+		path := MustCompilePath("$.a[?@.b > 1].b")
+		got, count, err := PatchCompiled([]byte(`{"a": [{"b": 1}, {"b": 2}, {"b": 3}]}`), path, func(v []byte) []byte {
+			return []byte(string(v) + "0")
+		})
+		assertPatch(t, got, count, err, `{"a": [{"b": 1}, {"b": 20}, {"b": 30}]}`, 2)
 	})
 	t.Run("PatchLargeJson", func(t *testing.T) {
 		var data = map[string]interface{}{}
@@ -106,19 +145,67 @@ func TestPatch(t *testing.T) {
 		data["501"].(map[string]interface{})["500"] = "new"
 		expected, _ := json.Marshal(data)
 
-		got, c1 := Patch(result, "$['500']", func([]byte) []byte {
+		got, c1, err := Patch(result, "$['500']", func([]byte) []byte {
 			return []byte(`"new"`)
 		})
-		got, c2 := Patch(got, "$['501'][\"500\"]", func([]byte) []byte {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, c2, err := Patch(got, "$['501'][\"500\"]", func([]byte) []byte {
 			return []byte(`"new"`)
 		})
-		assertPatch(t, got, c1+c2, string(expected), 2)
+		assertPatch(t, got, c1+c2, err, string(expected), 2)
+	})
+	t.Run("DocumentOrder", func(t *testing.T) {
+		data := []byte(`{"a": [1, {"b": 2}], "c": 3}`)
+		var seen []string
+		got, count, err := Patch(data, "$[-1:0:-1, 'c', 'a']..*", func(v []byte) []byte {
+			seen = append(seen, string(v))
+			return []byte("0")
+		})
+		assertPatch(t, got, count, err, `{"a": [0, 0], "c": 3}`, 2)
+		if strings.Join(seen, " ") != `1 {"b": 2}` {
+			t.Errorf("unexpected calls %q", seen)
+		}
+		got, count, err = Patch(data, "$..*", func(v []byte) []byte { return []byte("0") })
+		assertPatch(t, got, count, err, `{"a": 0, "c": 0}`, 2)
+		if string(data) != `{"a": [1, {"b": 2}], "c": 3}` {
+			t.Errorf("input modified: %s", data)
+		}
+	})
+	t.Run("AppendPatch", func(t *testing.T) {
+		data := []byte(`{"a": 1, "b": 2}`)
+		dst := []byte("x")
+		got, count, err := AppendPatch(dst, data, "$.*", func(v []byte) []byte { return append(v, '0') })
+		assertPatch(t, got, count, err, `x{"a": 10, "b": 20}`, 2)
+		got, count, err = AppendPatch(dst, data, "$.b", func(v []byte) []byte { return nil }, ValidateOutput())
+		if err == nil || count != 0 || string(got) != "x" {
+			t.Errorf("unexpected result %s, %d, %v", got, count, err)
+		}
+	})
+	t.Run("WritePatch", func(t *testing.T) {
+		var sb strings.Builder
+		items := make([]string, 10000)
+		for i := range items {
+			items[i] = strconv.Itoa(i)
+		}
+		data := []byte("[" + strings.Join(items, ", ") + "]")
+		count, err := WritePatch(&sb, data, "$[*]", func(v []byte) []byte { return []byte(`"` + string(v) + `"`) })
+		assertPatch(t, nil, count, err, "", 10000)
+		if want := `["` + strings.Join(items, `", "`) + `"]`; sb.String() != want {
+			t.Errorf("got %.64s..., want %.64s...", sb.String(), want)
+		}
 	})
 }
 
-func assertPatch(t *testing.T, got []byte, gotCount int, want string, wantCount int) {
+func assertPatch(t *testing.T, got []byte, gotCount int, err error, want string, wantCount int) {
 	t.Helper()
 
+	if err != nil {
+		t.Errorf("unexpected error %v", err)
+		return
+	}
+
 	if gotCount != wantCount {
 		t.Errorf("count = %v, want %v", gotCount, wantCount)
 		return
@@ -128,3 +215,94 @@ func assertPatch(t *testing.T, got []byte, gotCount int, want string, wantCount
 		t.Errorf("got %v, want %v", string(got), want)
 	}
 }
+
+func TestDelete(t *testing.T) {
+	for _, tc := range []struct {
+		data, path, want string
+		count            int
+	}{
+		{`{"a": 1, "b": 2, "c": 3}`, `$.a`, `{"b": 2, "c": 3}`, 1},
+		{`{"a": 1, "b": 2, "c": 3}`, `$.b`, `{"a": 1, "c": 3}`, 1},
+		{`{"a": 1, "b": 2, "c": 3}`, `$.c`, `{"a": 1, "b": 2}`, 1},
+		{`{"a": 1, "b": 2, "c": 3}`, `$['a','c']`, `{"b": 2}`, 2},
+		{`{"a": 1, "b": 2, "c": 3}`, `$['b','c']`, `{"a": 1}`, 2},
+		{`{"a": 1, "b": 2, "c": 3}`, `$.*`, `{}`, 3},
+		{`{ "a" : 1 }`, `$.a`, `{  }`, 1},
+		{"{\n\t\"a\": 1,\n\t\"b\": 2\n}", `$.b`, "{\n\t\"a\": 1\n}", 1},
+		{"{\n\t\"a\": 1,\n\t\"b\": 2\n}", `$.a`, "{\n\t\"b\": 2\n}", 1},
+		{`[1, 2, 3, 4, 5]`, `$[1,3]`, `[1, 3, 5]`, 2},
+		{`[1, 2, 3, 4, 5]`, `$[::2]`, `[2, 4]`, 3},
+		{`[1, 2, 3, 4, 5]`, `$[-2:]`, `[1, 2, 3]`, 2},
+		{`[1, [2, 3], {"a": [4]}]`, `$..[0]`, `[[3], {"a": []}]`, 3},
+		{`{"a": {"b": 1, "c": 2}, "d": [{"b": 3}]}`, `$..b`, `{"a": {"c": 2}, "d": [{}]}`, 2},
+		{`{"a": {"b": 1}}`, `$..*`, `{}`, 1},
+		{`{"x": {"x": 1}, "y": 2}`, `$..x`, `{"y": 2}`, 1},
+		{`[[1, 2], [3]]`, `$[0,0,1][*]`, `[[], []]`, 3},
+		{`{"a": [{"x": 1}, {"x": 2}, {"x": 3}]}`, `$.a[?@.x > 1]`, `{"a": [{"x": 1}]}`, 2},
+		{`{"a": 1}`, `$.b`, `{"a": 1}`, 0},
+	} {
+		data := []byte(tc.data)
+		got, count, err := Delete(data, tc.path)
+		if err != nil || string(got) != tc.want || count != tc.count {
+			t.Errorf("%s from %s: got %s, %d, %v, want %s, %d", tc.path, tc.data, got, count, err, tc.want, tc.count)
+		}
+		if string(data) != tc.data {
+			t.Errorf("%s from %s: input modified", tc.path, tc.data)
+		}
+	}
+	for _, tc := range [][2]string{{`{"a": 1}`, `$`}, {`{"a": 1}`, `$[`}, {`{"a": [1,,]}`, `$.a[0]`}} {
+		if _, _, err := Delete([]byte(tc[0]), tc[1]); err == nil {
+			t.Errorf("%s from %s: expected error", tc[1], tc[0])
+		}
+	}
+}
+
+func TestInsert(t *testing.T) {
+	events := `{"events": [
+	{"id": 1},
+	{ "id" : 2, "x" : [] },
+	{}
+]}`
+	got, count, err := InsertMember([]byte(events), `$.events[*]`, -1, "trace_id", []byte(`"t"`))
+	if want := `{"events": [
+	{"id": 1, "trace_id": "t"},
+	{ "id" : 2, "x" : [], "trace_id" : "t" },
+	{"trace_id": "t"}
+]}`; err != nil || count != 3 || string(got) != want {
+		t.Errorf("got %s, %d, %v, want %s", got, count, err, want)
+	}
+	got, count, err = InsertMember([]byte(events), `$.events[*]`, 0, "k", []byte(`0`))
+	if want := `{"events": [
+	{"k": 0, "id": 1},
+	{ "k" : 0, "id" : 2, "x" : [] },
+	{"k": 0}
+]}`; err != nil || count != 3 || string(got) != want {
+		t.Errorf("got %s, %d, %v, want %s", got, count, err, want)
+	}
+
+	for _, tc := range []struct {
+		data, path string
+		idx        int
+		want       string
+		count      int
+	}{
+		{`[1, 2, 3]`, `$`, 0, `[0, 1, 2, 3]`, 1},
+		{`[1, 2, 3]`, `$`, 1, `[1, 0, 2, 3]`, 1},
+		{`[1, 2, 3]`, `$`, 3, `[1, 2, 3, 0]`, 1},
+		{`[1, 2, 3]`, `$`, -1, `[1, 2, 3, 0]`, 1},
+		{`[1, 2, 3]`, `$`, -2, `[1, 2, 0, 3]`, 1},
+		{`[1, 2, 3]`, `$`, -4, `[0, 1, 2, 3]`, 1},
+		{`[1, 2, 3]`, `$`, 4, `[1, 2, 3]`, 0},
+		{`[1, 2, 3]`, `$`, -5, `[1, 2, 3]`, 0},
+		{`[]`, `$`, -1, `[0]`, 1},
+		{"[\n  1\n]", `$`, -1, "[\n  1,\n  0\n]", 1},
+		{`[[], [[1]], {"a": []}]`, `$..*`, -1, `[[0], [[1,0],0], {"a": [0]}]`, 4},
+		{`[[], [[1]], {"a": []}]`, `$..*`, 0, `[[0], [0,[0,1]], {"a": [0]}]`, 4},
+		{`{"a": 1}`, `$.a`, 0, `{"a": 1}`, 0},
+	} {
+		got, count, err := InsertElement([]byte(tc.data), tc.path, tc.idx, []byte("0"))
+		if err != nil || string(got) != tc.want || count != tc.count {
+			t.Errorf("%s %s %d: got %s, %d, %v, want %s, %d", tc.data, tc.path, tc.idx, got, count, err, tc.want, tc.count)
+		}
+	}
+}
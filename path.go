@@ -0,0 +1,99 @@
+package jsontk
+
+// Path is a compiled JSONPath, it's immutable and safe for concurrent use.
+type Path struct {
+	sel  []selector
+	root bool // filters in the path refer to the root
+}
+
+// CompilePath parses a JSONPath (RFC 9535) so that it could be used
+// repeatedly without being parsed again.
+func CompilePath(path string) (*Path, error) {
+	sel, err := parseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Path{sel: sel, root: usesRoot(sel)}, nil
+}
+
+// MustCompilePath is like [CompilePath] but panics if the path is invalid.
+func MustCompilePath(path string) *Path {
+	p, err := CompilePath(path)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// String returns the path in normalized form, in which every segment is in
+// bracket notation, e.g. $.a..b[?@.c] becomes $['a']..['b'][?@['c']].
+func (p *Path) String() string {
+	return string(appendSegments(append(make([]byte, 0, 32), '$'), p.sel))
+}
+
+func appendSegments(dst []byte, sel []selector) []byte {
+	for _, s := range sel {
+		if s == recursive {
+			dst = append(dst, '.', '.')
+			continue
+		}
+		dst = s.appendTo(append(dst, '['))
+		dst = append(dst, ']')
+	}
+	return dst
+}
+
+// NormalizedPath is the location of a value in a document, see section 2.7
+// of RFC 9535.
+type NormalizedPath []PathSegment
+
+// PathSegment is an object member or an array element in a [NormalizedPath].
+type PathSegment struct {
+	Key   []byte // quoted key of the object member, nil for array elements
+	Index int    // index of the array element
+}
+
+// Name returns the unquoted key of an object member segment.
+func (s PathSegment) Name() (string, bool) {
+	if s.Key == nil {
+		return "", false
+	}
+	return unquote(s.Key)
+}
+
+// AppendTo appends the path in normalized form, e.g. $['a'][0], to dst.
+func (p NormalizedPath) AppendTo(dst []byte) []byte {
+	dst = append(dst, '$')
+	for _, s := range p {
+		if s.Key != nil {
+			dst = appendNormalizedKey(dst, s.Key)
+		} else {
+			dst = appendNormalizedIndex(dst, s.Index)
+		}
+	}
+	return dst
+}
+
+func (p NormalizedPath) String() string {
+	return string(p.AppendTo(make([]byte, 0, 32)))
+}
+
+// pushKey, pushIndex and pop maintain the path while traversing, they do
+// nothing on a nil path.
+func (p *NormalizedPath) pushKey(key *Token) {
+	if p != nil {
+		*p = append(*p, PathSegment{Key: key.Value})
+	}
+}
+
+func (p *NormalizedPath) pushIndex(idx int) {
+	if p != nil {
+		*p = append(*p, PathSegment{Index: idx})
+	}
+}
+
+func (p *NormalizedPath) pop() {
+	if p != nil {
+		*p = (*p)[:len(*p)-1]
+	}
+}
@@ -0,0 +1,99 @@
+package jsontk
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCompilePath(t *testing.T) {
+	for path, want := range map[string]string{
+		`$`:                               `$`,
+		`$.a.b`:                           `$['a']['b']`,
+		`$['a',"b\""][0,-1]`:              `$['a','b"'][0,-1]`,
+		`$..a.*[*]`:                       `$..['a'][*][*]`,
+		`$..[1:2]`:                        `$..[1:2]`,
+		`$[::2]`:                          `$[::2]`,
+		`$[?@.a == 'x' && (@.b || !@.c)]`: `$[?@['a'] == "x" && (@['b'] || !@['c'])]`,
+		`$[?!(@.a < 1)]`:                  `$[?!(@['a'] < 1)]`,
+		`$[?count($..*) >= 1]`:            `$[?count($..[*]) >= 1]`,
+		`$['é\n']`:                        `$['é\n']`,
+	} {
+		p, err := CompilePath(path)
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+			continue
+		}
+		if p.String() != want {
+			t.Errorf("%s: got %s, want %s", path, p.String(), want)
+		}
+		if q, err := CompilePath(p.String()); err != nil || q.String() != want {
+			t.Errorf("%s: normalized form doesn't round trip: %v", path, err)
+		}
+	}
+}
+
+func TestSelectCompiledConcurrent(t *testing.T) {
+	p := MustCompilePath(`$.store[?@.price < 10].title`)
+	data := []byte(`{"store": [{"price": 5, "title": "a"}, {"price": 15, "title": "b"}]}`)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var iter Iterator
+			for j := 0; j < 100; j++ {
+				iter.Reset(data)
+				cnt := 0
+				if err := iter.SelectCompiled(p, func(iter *Iterator) {
+					var tk Token
+					if iter.NextToken(&tk).String() != "a" {
+						t.Errorf("unexpected value %s", tk.Value)
+					}
+					cnt++
+				}); err != nil || cnt != 1 {
+					t.Errorf("unexpected result %v, %d", err, cnt)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestSelectWithPath(t *testing.T) {
+	data := []byte(`{"a": [{"b": 1}, {"b": 2, "c\n'": 3}], "d": {"b": [4]}}`)
+	// the selectors of a bracketed selection are applied in turn, as in
+	// RFC 9535, so the filter in $[?@.b, 'a'] selects 'd' before 'a'
+	for path, want := range map[string][]string{
+		`$.a[*].b`:        {`$['a'][0]['b']`, `$['a'][1]['b']`},
+		`$..b`:            {`$['a'][0]['b']`, `$['a'][1]['b']`, `$['d']['b']`},
+		`$.a[-1].*`:       {`$['a'][1]['b']`, `$['a'][1]['c\n\'']`},
+		`$.a[::-1].b`:     {`$['a'][1]['b']`, `$['a'][0]['b']`},
+		`$[?@.b, 'a'][*]`: {`$['d']['b']`, `$['a'][0]`, `$['a'][1]`},
+		`$..[0]`:          {`$['a'][0]`, `$['d']['b'][0]`},
+	} {
+		var got []string
+		var iter Iterator
+		iter.Reset(data)
+		if err := iter.SelectWithPath(path, func(p NormalizedPath, iter *Iterator) {
+			got = append(got, p.String())
+			iter.Skip()
+		}); err != nil {
+			t.Errorf("%s: %v", path, err)
+		}
+		if strings.Join(got, " ") != strings.Join(want, " ") {
+			t.Errorf("%s: got %q, want %q", path, got, want)
+		}
+	}
+	var iter Iterator
+	iter.Reset(data)
+	iter.SelectWithPath(`$.a[1]['c\n\'']`, func(p NormalizedPath, iter *Iterator) {
+		iter.Skip()
+		if name, ok := p[0].Name(); !ok || name != "a" || p[1].Key != nil || p[1].Index != 1 {
+			t.Errorf("unexpected segments %v", p)
+		}
+		if name, _ := p[2].Name(); name != "c\n'" {
+			t.Errorf("unexpected name %q", name)
+		}
+	})
+}
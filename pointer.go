@@ -0,0 +1,190 @@
+package jsontk
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Pointer is a compiled JSON Pointer (RFC 6901), it's immutable and safe
+// for concurrent use.
+type Pointer struct {
+	tokens []string // unescaped reference tokens
+	sel    []selector
+}
+
+// CompilePointer parses a JSON Pointer, e.g. /a/b~1c/0.
+func CompilePointer(ptr string) (*Pointer, error) {
+	if ptr != "" && ptr[0] != '/' {
+		return nil, fmt.Errorf("%w: %q doesn't start with /", ErrInvalidPointer, ptr)
+	}
+	p := &Pointer{}
+	for ptr != "" {
+		ptr = ptr[1:]
+		end := strings.IndexByte(ptr, '/')
+		if end < 0 {
+			end = len(ptr)
+		}
+		token, err := unescapePointerToken(ptr[:end])
+		if err != nil {
+			return nil, err
+		}
+		p.tokens = append(p.tokens, token)
+		ptr = ptr[end:]
+	}
+	p.sel = make([]selector, len(p.tokens))
+	for i, token := range p.tokens {
+		p.sel[i] = newTokenSelector(token)
+	}
+	return p, nil
+}
+
+// MustCompilePointer is like [CompilePointer] but panics if the pointer is invalid.
+func MustCompilePointer(ptr string) *Pointer {
+	p, err := CompilePointer(ptr)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// PointerFromPath converts a singular path, in which every segment is a
+// single name or non-negative index selector, to a JSON Pointer.
+func PointerFromPath(p *Path) (*Pointer, error) {
+	ptr := &Pointer{tokens: make([]string, len(p.sel)), sel: make([]selector, len(p.sel))}
+	for i, s := range p.sel {
+		switch s := s.(type) {
+		case nameSelector:
+			ptr.tokens[i] = string(s)
+		case indexSelector:
+			if s < 0 {
+				return nil, fmt.Errorf("%w: negative index in %s", ErrInvalidPointer, p)
+			}
+			ptr.tokens[i] = strconv.Itoa(int(s))
+		default:
+			return nil, fmt.Errorf("%w: %s is not a singular path", ErrInvalidPointer, p)
+		}
+		ptr.sel[i] = newTokenSelector(ptr.tokens[i])
+	}
+	return ptr, nil
+}
+
+// Pointer converts the normalized path to a JSON Pointer. It fails if a key
+// can't be unquoted, which may happen with keys read without validation.
+func (p NormalizedPath) Pointer() (*Pointer, error) {
+	ptr := &Pointer{tokens: make([]string, len(p)), sel: make([]selector, len(p))}
+	for i, s := range p {
+		if s.Key == nil {
+			ptr.tokens[i] = strconv.Itoa(s.Index)
+		} else if name, ok := s.Name(); ok {
+			ptr.tokens[i] = name
+		} else {
+			return nil, fmt.Errorf("%w: invalid key %s in %s", ErrInvalidPointer, s.Key, p)
+		}
+		ptr.sel[i] = newTokenSelector(ptr.tokens[i])
+	}
+	return ptr, nil
+}
+
+// Tokens returns the unescaped reference tokens of the pointer.
+func (p *Pointer) Tokens() []string {
+	return append([]string(nil), p.tokens...)
+}
+
+// String returns the pointer with its reference tokens escaped.
+func (p *Pointer) String() string {
+	var sb strings.Builder
+	for _, token := range p.tokens {
+		sb.WriteByte('/')
+		sb.WriteString(pointerEscaper.Replace(token))
+	}
+	return sb.String()
+}
+
+// Path converts the pointer to a JSONPath. Whether a token like "0" refers
+// to an object member or an array element depends on the document, so such
+// tokens become a selection of both, e.g. /a/0 becomes $['a']['0',0].
+func (p *Pointer) Path() *Path {
+	sel := make([]selector, len(p.sel))
+	for i, s := range p.sel {
+		if s := s.(tokenSelector); s.index >= 0 {
+			sel[i] = combineSelector{s.name, s.index}
+		} else {
+			sel[i] = s.name
+		}
+	}
+	return &Path{sel: sel}
+}
+
+// SelectPointer calls cb on the value referenced by the JSON Pointer, if
+// it exists. cb MUST consume the value, as in [Iterator.Select].
+func (iter *Iterator) SelectPointer(ptr string, cb func(iter *Iterator)) error {
+	p, err := CompilePointer(ptr)
+	if err != nil {
+		return err
+	}
+	return iter.SelectCompiledPointer(p, cb)
+}
+
+// SelectCompiledPointer is like [Iterator.SelectPointer] but takes a
+// compiled pointer.
+func (iter *Iterator) SelectCompiledPointer(p *Pointer, cb func(iter *Iterator)) error {
+	traverse(iter, p.sel, nil, cb)
+	return iter.Error
+}
+
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+func unescapePointerToken(s string) (string, error) {
+	if strings.IndexByte(s, '~') < 0 {
+		return s, nil
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '~' {
+			sb.WriteByte(s[i])
+			continue
+		}
+		if i+1 == len(s) || s[i+1] != '0' && s[i+1] != '1' {
+			return "", fmt.Errorf("%w: invalid escape in %q", ErrInvalidPointer, s)
+		}
+		if i++; s[i] == '0' {
+			sb.WriteByte('~')
+		} else {
+			sb.WriteByte('/')
+		}
+	}
+	return sb.String(), nil
+}
+
+// tokenSelector selects by a reference token of a JSON Pointer, which is a
+// member name for objects, or an index for arrays if it's in the form of one.
+// The "-" token refers to the position after the last element, which
+// doesn't exist until something is appended.
+type tokenSelector struct {
+	name  nameSelector
+	index indexSelector // -1 if the token isn't an array index
+}
+
+func newTokenSelector(token string) tokenSelector {
+	s := tokenSelector{name: nameSelector(token), index: -1}
+	if token == "0" || token != "" && token[0] >= '1' && token[0] <= '9' {
+		if i, err := strconv.Atoi(token); err == nil && strconv.Itoa(i) == token {
+			s.index = indexSelector(i)
+		}
+	}
+	return s
+}
+
+func (s tokenSelector) SelectArr(idx int, iter *Iterator) bool {
+	return s.index >= 0 && s.index.SelectArr(idx, iter)
+}
+func (s tokenSelector) SelectObj(key *Token, iter *Iterator) bool {
+	return s.name.SelectObj(key, iter)
+}
+func (s tokenSelector) appendTo(dst []byte) []byte {
+	if s.index < 0 {
+		return s.name.appendTo(dst)
+	}
+	return s.index.appendTo(append(s.name.appendTo(dst), ','))
+}
@@ -0,0 +1,112 @@
+package jsontk
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSelectPointer(t *testing.T) {
+	// the example in section 5 of RFC 6901
+	data := []byte(`{
+	"foo": ["bar", "baz"],
+	"": 0,
+	"a/b": 1,
+	"c%d": 2,
+	"e^f": 3,
+	"g|h": 4,
+	"i\\j": 5,
+	"k\"l": 6,
+	" ": 7,
+	"m~n": 8,
+	"01": 9
+}`)
+	for ptr, want := range map[string]string{
+		"":        `{`,
+		"/foo":    `["bar", "baz"]`,
+		"/foo/0":  `"bar"`,
+		"/":       `0`,
+		"/a~1b":   `1`,
+		"/c%d":    `2`,
+		"/e^f":    `3`,
+		"/g|h":    `4`,
+		"/i\\j":   `5`,
+		"/k\"l":   `6`,
+		"/ ":      `7`,
+		"/m~0n":   `8`,
+		"/01":     `9`,
+		"/foo/2":  ``,
+		"/foo/-":  ``,
+		"/foo/01": ``,
+		"/bar":    ``,
+	} {
+		var got string
+		var iter Iterator
+		iter.Reset(data)
+		if err := iter.SelectPointer(ptr, func(iter *Iterator) {
+			_, i, l := iter.Skip()
+			got = string(iter.data[i : i+l])
+		}); err != nil {
+			t.Errorf("%q: %v", ptr, err)
+		}
+		if ptr == "" {
+			got = got[:1]
+		}
+		if got != want {
+			t.Errorf("%q: got %s, want %s", ptr, got, want)
+		}
+		if p := MustCompilePointer(ptr); p.String() != ptr {
+			t.Errorf("%q: doesn't round trip, got %q", ptr, p.String())
+		}
+	}
+	for _, ptr := range []string{"a", "/a~", "/a~2"} {
+		if _, err := CompilePointer(ptr); !errors.Is(err, ErrInvalidPointer) {
+			t.Errorf("%q should be invalid, got %v", ptr, err)
+		}
+	}
+}
+
+func TestPointerConversion(t *testing.T) {
+	for ptr, path := range map[string]string{
+		"":          `$`,
+		"/a~1b/~0":  `$['a/b']['~']`,
+		"/a/0/-/01": `$['a']['0',0]['-']['01']`,
+	} {
+		if got := MustCompilePointer(ptr).Path().String(); got != path {
+			t.Errorf("%q: got %s, want %s", ptr, got, path)
+		}
+	}
+	p, err := PointerFromPath(MustCompilePath(`$['a/b'][0]['~']`))
+	if err != nil || p.String() != "/a~1b/0/~0" {
+		t.Errorf("unexpected pointer %v, %v", p, err)
+	}
+	if _, err := PointerFromPath(MustCompilePath(`$.a[*]`)); !errors.Is(err, ErrInvalidPointer) {
+		t.Errorf("non-singular path should be rejected, got %v", err)
+	}
+
+	data := []byte(`{"a": [{"b/c": 1}, 2], "~": {}}`)
+	var iter Iterator
+	iter.Reset(data)
+	cnt := 0
+	iter.SelectWithPath(`$..*`, func(path NormalizedPath, iter *Iterator) {
+		_, i, l := iter.Skip()
+		ptr, err := path.Pointer()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var sub Iterator
+		sub.Reset(data)
+		sub.SelectCompiledPointer(ptr, func(sub *Iterator) {
+			_, j, m := sub.Skip()
+			if i != j || l != m {
+				t.Errorf("%s: pointer %s selects a different value", path, ptr)
+			}
+			cnt++
+		})
+	})
+	if cnt != 5 {
+		t.Errorf("expected 5 values, got %d", cnt)
+	}
+	if _, err := (NormalizedPath{{Key: []byte(`"\x"`)}}).Pointer(); !errors.Is(err, ErrInvalidPointer) {
+		t.Errorf("invalid key should be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package jsontk
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// Word-at-a-time (SWAR) scanning, which reads 8 bytes at once and finds the
+// bytes of interest with bitwise arithmetic. Architectures with vector
+// instructions override indexQuote and indexSpecial, see scan_amd64.s and
+// scan_arm64.s.
+
+const (
+	lsb = 0x0101010101010101
+	msb = 0x8080808080808080
+)
+
+// zeros returns a word with the high bit set in each zero byte of x, and
+// nothing else set. Unlike the usual (x-lsb)&^x&msb, it's exact for every
+// byte, not just the first zero.
+func zeros(x uint64) uint64 {
+	return ^((x&^msb + ^uint64(msb)) | x) & msb
+}
+
+// spaces returns a word with the high bit set in each byte of x which is
+// json whitespace.
+func spaces(x uint64) uint64 {
+	return zeros(x^(lsb*' ')) | zeros(x^(lsb*'\n')) | zeros(x^(lsb*'\t')) | zeros(x^(lsb*'\r'))
+}
+
+// indexQuoteSWAR returns the index of the first quote or backslash in s, or
+// len(s) if there's none.
+func indexQuoteSWAR(s []byte) int {
+	i := 0
+	for ; len(s)-i >= 8; i += 8 {
+		x := binary.LittleEndian.Uint64(s[i:])
+		if m := zeros(x^(lsb*'"')) | zeros(x^(lsb*'\\')); m != 0 {
+			return i + bits.TrailingZeros64(m)/8
+		}
+	}
+	for ; i < len(s) && s[i] != '"' && s[i] != '\\'; i++ {
+	}
+	return i
+}
+
+// indexSpecialSWAR returns the index of the first byte in s which is not
+// in plainChars, or len(s) if there's none.
+func indexSpecialSWAR(s []byte) int {
+	i := 0
+	for ; len(s)-i >= 8; i += 8 {
+		x := binary.LittleEndian.Uint64(s[i:])
+		// control characters are the bytes with none of the top 3 bits set
+		if m := zeros(x^(lsb*'"')) | zeros(x^(lsb*'\\')) | zeros(x&(lsb*0xe0)) | x&msb; m != 0 {
+			return i + bits.TrailingZeros64(m)/8
+		}
+	}
+	for ; i < len(s) && plainChars[s[i]]; i++ {
+	}
+	return i
+}
+
+// skipSpaceSWAR returns the index of the first byte in s which is not json
+// whitespace, or len(s) if there's none.
+func skipSpaceSWAR(s []byte) int {
+	i := 0
+	for ; len(s)-i >= 8; i += 8 {
+		if m := ^spaces(binary.LittleEndian.Uint64(s[i:])) & msb; m != 0 {
+			return i + bits.TrailingZeros64(m)/8
+		}
+	}
+	for ; i < len(s); i++ {
+		switch s[i] {
+		case ' ', '\n', '\t', '\r':
+		default:
+			return i
+		}
+	}
+	return i
+}
@@ -0,0 +1,15 @@
+//go:build amd64 && !purego
+
+package jsontk
+
+// indexQuote returns the index of the first quote or backslash in s, or
+// len(s) if there's none.
+//
+//go:noescape
+func indexQuote(s []byte) int
+
+// indexSpecial returns the index of the first byte in s which is not in
+// plainChars, or len(s) if there's none.
+//
+//go:noescape
+func indexSpecial(s []byte) int
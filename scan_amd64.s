@@ -0,0 +1,115 @@
+//go:build amd64 && !purego
+
+#include "textflag.h"
+
+// Both functions compare 16 bytes at a time with SSE2, which every amd64
+// processor has, leaving the bitmask of the bytes found in AX. The last 16
+// bytes are read again if the length isn't a multiple of 16, and shorter
+// inputs are read byte by byte.
+
+#define BROADCAST(c, x) \
+	MOVQ $c, AX; \
+	MOVQ AX, x; \
+	PUNPCKLQDQ x, x
+
+// X1 and X2 hold '"' and '\\'
+#define QUOTES(addr) \
+	MOVOU addr, X0; \
+	MOVO X0, X3; \
+	PCMPEQB X1, X0; \
+	PCMPEQB X2, X3; \
+	POR X3, X0; \
+	PMOVMSKB X0, AX
+
+// X4 holds ' ', which is greater than control characters and non-ASCII
+// bytes as signed bytes
+#define SPECIALS(addr) \
+	MOVOU addr, X0; \
+	MOVO X0, X3; \
+	MOVO X4, X5; \
+	PCMPGTB X0, X5; \
+	PCMPEQB X1, X0; \
+	PCMPEQB X2, X3; \
+	POR X3, X0; \
+	POR X5, X0; \
+	PMOVMSKB X0, AX
+
+// SCAN runs the loop on the input in SI and BX, starting at DI, returning
+// the index of the first byte found by MASK, or jumping to bytes for inputs
+// shorter than 16 bytes.
+#define SCAN(MASK) \
+	CMPQ BX, $16; \
+	JB bytes; \
+loop: \
+	MASK((SI)); \
+	TESTL AX, AX; \
+	JNZ found; \
+	ADDQ $16, SI; \
+	SUBQ $16, BX; \
+	CMPQ BX, $16; \
+	JAE loop; \
+	TESTQ BX, BX; \
+	JZ done; \
+	LEAQ -16(SI)(BX*1), R8; \
+	MASK((R8)); \
+	MOVQ $16, CX; \
+	SUBQ BX, CX; \
+	SHRL CX, AX; \
+	TESTL AX, AX; \
+	JNZ found; \
+	ADDQ BX, SI; \
+	JMP done; \
+found: \
+	BSFL AX, AX; \
+	ADDQ AX, SI; \
+done: \
+	SUBQ DI, SI; \
+	MOVQ SI, ret+24(FP); \
+	RET
+
+// func indexQuote(s []byte) int
+TEXT ·indexQuote(SB), NOSPLIT, $0-32
+	MOVQ s_base+0(FP), SI
+	MOVQ s_len+8(FP), BX
+	MOVQ SI, DI
+	BROADCAST(0x2222222222222222, X1)
+	BROADCAST(0x5c5c5c5c5c5c5c5c, X2)
+	SCAN(QUOTES)
+
+bytes:
+	TESTQ BX, BX
+	JZ    done
+	MOVB  (SI), AX
+	CMPB  AL, $0x22
+	JEQ   done
+	CMPB  AL, $0x5c
+	JEQ   done
+	INCQ  SI
+	DECQ  BX
+	JMP   bytes
+
+// func indexSpecial(s []byte) int
+TEXT ·indexSpecial(SB), NOSPLIT, $0-32
+	MOVQ s_base+0(FP), SI
+	MOVQ s_len+8(FP), BX
+	MOVQ SI, DI
+	BROADCAST(0x2222222222222222, X1)
+	BROADCAST(0x5c5c5c5c5c5c5c5c, X2)
+	BROADCAST(0x2020202020202020, X4)
+	SCAN(SPECIALS)
+
+bytes:
+	TESTQ BX, BX
+	JZ    done
+	MOVB  (SI), AX
+	CMPB  AL, $0x22
+	JEQ   done
+	CMPB  AL, $0x5c
+	JEQ   done
+	CMPB  AL, $0x20
+	JB    done
+	TESTB $0x80, AL
+	JNZ   done
+	INCQ  SI
+	DECQ  BX
+	JMP   bytes
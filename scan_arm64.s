@@ -0,0 +1,101 @@
+//go:build arm64 && !purego
+
+#include "textflag.h"
+
+// Both functions compare 16 bytes at a time with NEON, which every arm64
+// processor has, until a block contains a byte found by MASK, leaving its
+// lanes set in V3. The byte is then located by the byte by byte loop, which
+// also reads the last bytes if the length isn't a multiple of 16.
+
+// V1 and V2 hold '"' and '\\'
+#define QUOTES \
+	VCMEQ V1.B16, V0.B16, V3.B16; \
+	VCMEQ V2.B16, V0.B16, V4.B16; \
+	VORR  V4.B16, V3.B16, V3.B16
+
+// V5 holds ' ' and V6 holds 0x7f, control characters are below the former
+// and non-ASCII bytes above the latter
+#define SPECIALS \
+	QUOTES; \
+	VCMHI V0.B16, V5.B16, V4.B16; \
+	VORR  V4.B16, V3.B16, V3.B16; \
+	VCMHI V6.B16, V0.B16, V4.B16; \
+	VORR  V4.B16, V3.B16, V3.B16
+
+// SCAN runs the loop on the input in R0 and R1, starting at R2, jumping to
+// bytes at the block containing the first byte found by MASK or at the last
+// bytes.
+#define SCAN(MASK) \
+loop: \
+	CMP    $16, R1; \
+	BLO    bytes; \
+	VLD1   (R0), [V0.B16]; \
+	MASK; \
+	VUMAXV V3.B16, V3; \
+	VMOV   V3.D[0], R3; \
+	CBNZ   R3, bytes; \
+	ADD    $16, R0; \
+	SUB    $16, R1; \
+	B      loop
+
+// func indexQuote(s []byte) int
+TEXT ·indexQuote(SB), NOSPLIT, $0-32
+	MOVD s_base+0(FP), R0
+	MOVD s_len+8(FP), R1
+	MOVD R0, R2
+	MOVD $0x22, R3
+	VMOV R3, V1.B16
+	MOVD $0x5c, R3
+	VMOV R3, V2.B16
+	SCAN(QUOTES)
+
+bytes:
+	CBZ   R1, done
+	MOVBU (R0), R3
+	CMP   $0x22, R3
+	BEQ   done
+	CMP   $0x5c, R3
+	BEQ   done
+	ADD   $1, R0
+	SUB   $1, R1
+	B     bytes
+
+done:
+	SUB  R2, R0, R0
+	MOVD R0, ret+24(FP)
+	RET
+
+// func indexSpecial(s []byte) int
+TEXT ·indexSpecial(SB), NOSPLIT, $0-32
+	MOVD s_base+0(FP), R0
+	MOVD s_len+8(FP), R1
+	MOVD R0, R2
+	MOVD $0x22, R3
+	VMOV R3, V1.B16
+	MOVD $0x5c, R3
+	VMOV R3, V2.B16
+	MOVD $0x20, R3
+	VMOV R3, V5.B16
+	MOVD $0x7f, R3
+	VMOV R3, V6.B16
+	SCAN(SPECIALS)
+
+bytes:
+	CBZ   R1, done
+	MOVBU (R0), R3
+	CMP   $0x22, R3
+	BEQ   done
+	CMP   $0x5c, R3
+	BEQ   done
+	CMP   $0x20, R3
+	BLO   done
+	CMP   $0x80, R3
+	BHS   done
+	ADD   $1, R0
+	SUB   $1, R1
+	B     bytes
+
+done:
+	SUB  R2, R0, R0
+	MOVD R0, ret+24(FP)
+	RET
@@ -0,0 +1,15 @@
+//go:build (!amd64 && !arm64) || purego
+
+package jsontk
+
+// indexQuote returns the index of the first quote or backslash in s, or
+// len(s) if there's none.
+func indexQuote(s []byte) int {
+	return indexQuoteSWAR(s)
+}
+
+// indexSpecial returns the index of the first byte in s which is not in
+// plainChars, or len(s) if there's none.
+func indexSpecial(s []byte) int {
+	return indexSpecialSWAR(s)
+}
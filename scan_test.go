@@ -0,0 +1,47 @@
+package jsontk
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestScan(t *testing.T) {
+	naive := func(s []byte, stop func(c byte) bool) int {
+		for i, c := range s {
+			if stop(c) {
+				return i
+			}
+		}
+		return len(s)
+	}
+	isQuote := func(c byte) bool { return c == '"' || c == '\\' }
+	isSpecial := func(c byte) bool { return !plainChars[c] }
+	isNotSpace := func(c byte) bool { return c != ' ' && c != '\n' && c != '\t' && c != '\r' }
+	for name, f := range map[string]struct {
+		got   func([]byte) int
+		stop  func(byte) bool
+		plain string // bytes it runs over, so that the one it stops at can be anywhere
+	}{
+		"indexQuote":       {indexQuote, isQuote, "a \t\xe9"},
+		"indexQuoteSWAR":   {indexQuoteSWAR, isQuote, "a \t\xe9"},
+		"indexSpecial":     {indexSpecial, isSpecial, "a b/"},
+		"indexSpecialSWAR": {indexSpecialSWAR, isSpecial, "a b/"},
+		"skipSpaceSWAR":    {skipSpaceSWAR, isNotSpace, " \t\n\r"},
+	} {
+		r := rand.New(rand.NewSource(1))
+		buf := make([]byte, 128)
+		for n := 0; n < 20000; n++ {
+			for i := range buf {
+				buf[i] = f.plain[r.Intn(len(f.plain))]
+			}
+			for k := r.Intn(3); k > 0; k-- {
+				buf[r.Intn(len(buf))] = byte(r.Intn(256))
+			}
+			start := r.Intn(17)
+			s := buf[start : start+r.Intn(len(buf)-start)]
+			if got, want := f.got(s), naive(s, f.stop); got != want {
+				t.Fatalf("%s(%q) = %d, want %d", name, s, got, want)
+			}
+		}
+	}
+}
@@ -7,73 +7,176 @@ import (
 	"unicode/utf8"
 )
 
-var minInt = -1 << (32<<(^uint(0)>>63) - 1)
-
+// Select calls cb on each value selected by the JSONPath (RFC 9535), with
+// iter positioned at the value. cb MUST consume the value, for example with
+// [Iterator.Skip]. In reader mode, values under descendant segments and
+// bracketed selections, values tested by filters and arrays indexed from
+// the end are kept in the window while being processed.
 func (iter *Iterator) Select(path string, cb func(iter *Iterator)) error {
-	selectors, err := parseJSONPath(path)
+	p, err := CompilePath(path)
+	if err != nil {
+		return err
+	}
+	return iter.SelectCompiled(p, cb)
+}
+
+// SelectCompiled is like [Iterator.Select] but takes a compiled path.
+func (iter *Iterator) SelectCompiled(p *Path, cb func(iter *Iterator)) error {
+	if p.root {
+		defer iter.withRoot()()
+	}
+	traverse(iter, p.sel, nil, cb)
+	return iter.Error
+}
+
+// SelectWithPath is like [Iterator.Select], but cb also receives the
+// normalized path of the selected value. The path is only valid within cb.
+func (iter *Iterator) SelectWithPath(path string, cb func(path NormalizedPath, iter *Iterator)) error {
+	p, err := CompilePath(path)
 	if err != nil {
 		return err
 	}
-	traverse(iter, selectors, cb)
+	return iter.SelectCompiledWithPath(p, cb)
+}
+
+// SelectCompiledWithPath is like [Iterator.SelectWithPath] but takes a
+// compiled path.
+func (iter *Iterator) SelectCompiledWithPath(p *Path, cb func(path NormalizedPath, iter *Iterator)) error {
+	if p.root {
+		defer iter.withRoot()()
+	}
+	path := make(NormalizedPath, 0, 8)
+	traverse(iter, p.sel, &path, func(iter *Iterator) { cb(path, iter) })
 	return iter.Error
 }
 
-func traverse(iter *Iterator, sel []selector, f func(iter *Iterator)) {
+// withRoot keeps the next value in the window as the root for filter
+// queries, until the returned function is called.
+func (iter *Iterator) withRoot() func() {
+	iter.pin++
+	iter.skipSpace()
+	save := iter.head
+	_, loc, length := iter.Skip()
+	iter.root, iter.head = iter.data[loc:loc+length], save
+	return func() { iter.pin--; iter.root = nil }
+}
+
+// traverse applies the selectors to the next value and calls f on each
+// selected value. If path isn't nil, it's kept as the normalized path of
+// the value being visited.
+func traverse(iter *Iterator, sel []selector, path *NormalizedPath, f func(iter *Iterator)) {
 	if len(sel) == 0 {
 		f(iter)
 		return
 	}
+	if sel[0] == recursive {
+		traverseDescendants(iter, sel, path, f)
+		return
+	}
+	if union, ok := sel[0].(combineSelector); ok {
+		traverseUnion(iter, union, sel[1:], path, f)
+		return
+	}
 	switch iter.Peek() {
 	case BEGIN_OBJECT:
 		iter.NextObject(func(key *Token) bool {
-			if sel[0] == recursive {
-				save := iter.head
-				traverse(iter, sel, f)
-				iter.head = save
-			}
-			if sel[0].SelectObj(key, iter) {
-				traverse(iter, sel[1:], f)
+			k := *key // filters may read other keys
+			if sel[0].SelectObj(&k, iter) {
+				path.pushKey(&k)
+				traverse(iter, sel[1:], path, f)
+				path.pop()
 			} else {
-				iter.Skip()
+				iter.discard()
 			}
 			return true
 		})
 	case BEGIN_ARRAY:
-		if traverseInversedArr(iter, sel[0], f) {
+		if traverseInversedArr(iter, sel, path, f) {
 			return
 		}
 		iter.NextArray(func(idx int) bool {
-			if sel[0] == recursive {
-				save := iter.head
-				traverse(iter, sel, f)
-				iter.head = save
-			}
 			if sel[0].SelectArr(idx, iter) {
-				traverse(iter, sel[1:], f)
+				path.pushIndex(idx)
+				traverse(iter, sel[1:], path, f)
+				path.pop()
 			} else {
-				iter.Skip()
+				iter.discard()
 			}
 			return true
 		})
 	default:
-		iter.Skip()
+		iter.discard()
+	}
+}
+
+// traverseUnion applies the selectors of a bracketed selection one after
+// another to the current value, which is kept in the window and read once
+// for each of them. Values selected more than once are visited every time,
+// as specified in RFC 9535.
+func traverseUnion(iter *Iterator, union combineSelector, rest []selector, path *NormalizedPath, f func(iter *Iterator)) {
+	iter.skipSpace()
+	save := iter.head
+	iter.pin++
+	defer func() { iter.pin-- }()
+	sel := append([]selector{nil}, rest...)
+	for _, sel[0] = range union {
+		iter.head = save
+		traverse(iter, sel, path, f)
+		if iter.Error != nil {
+			return
+		}
+	}
+}
+
+// traverseDescendants applies the selector following ".." to the current
+// value and all of its descendants. The current value is kept in the window
+// and read twice, so that results are ordered as specified in RFC 9535.
+func traverseDescendants(iter *Iterator, sel []selector, path *NormalizedPath, f func(iter *Iterator)) {
+	iter.skipSpace()
+	save := iter.head
+	iter.pin++
+	traverse(iter, sel[1:], path, f)
+	iter.pin--
+	if iter.Error != nil {
+		return
+	}
+	iter.head = save
+	switch iter.Peek() {
+	case BEGIN_OBJECT:
+		iter.NextObject(func(key *Token) bool {
+			path.pushKey(key)
+			traverseDescendants(iter, sel, path, f)
+			path.pop()
+			return true
+		})
+	case BEGIN_ARRAY:
+		iter.NextArray(func(idx int) bool {
+			path.pushIndex(idx)
+			traverseDescendants(iter, sel, path, f)
+			path.pop()
+			return true
+		})
+	default:
+		iter.discard()
 	}
 }
 
-func traverseInversedArr(iter *Iterator, sel selector, f func(iter *Iterator)) bool {
-	switch sel := sel.(type) {
+func traverseInversedArr(iter *Iterator, sel []selector, path *NormalizedPath, f func(iter *Iterator)) bool {
+	switch sel := sel[0].(type) {
 	case indexSelector:
 		if sel >= 0 {
 			return false
 		}
 	case *arrSliceSelector:
-		if sel.start >= 0 && (sel.end == -1 || sel.end >= 0) && sel.step >= 0 {
+		if !sel.needsLen() {
 			return false
 		}
 	default:
 		return false
 	}
 	indexes := make([]int, 0, 10)
+	iter.pin++
+	defer func() { iter.pin-- }()
 	if err := iter.NextArray(func(idx int) bool {
 		_, i, _ := iter.Skip()
 		indexes = append(indexes, i)
@@ -82,24 +185,26 @@ func traverseInversedArr(iter *Iterator, sel selector, f func(iter *Iterator)) b
 		return false
 	}
 	after := iter.head
-	switch sel := sel.(type) {
+	visit := func(idx int) {
+		iter.head = indexes[idx]
+		path.pushIndex(idx)
+		traverse(iter, sel[1:], path, f)
+		path.pop()
+	}
+	switch sel := sel[0].(type) {
 	case indexSelector:
-		iter.head = indexes[len(indexes)+int(sel)]
-		f(iter)
-	case *arrSliceSelector:
-		s, e := sel.start, sel.end
-		if s < 0 {
-			s += len(indexes)
+		if idx := len(indexes) + int(sel); idx >= 0 {
+			visit(idx)
 		}
-		if e == minInt {
-			e = -1
-		} else if e < 0 {
-			e += len(indexes)
-		}
-		for ; (e-s)*sel.step > 0; s += sel.step {
-			if s >= 0 && s < len(indexes) {
-				iter.head = indexes[s]
-				f(iter)
+	case *arrSliceSelector:
+		lower, upper := sel.bounds(len(indexes))
+		if sel.step > 0 {
+			for i := lower; i < upper && iter.Error == nil; i += sel.step {
+				visit(i)
+			}
+		} else {
+			for i := upper; lower < i && iter.Error == nil; i += sel.step {
+				visit(i)
 			}
 		}
 	}
@@ -122,19 +227,36 @@ var emptyChar = func() (as as) {
 	return
 }()
 
-var alphaDigitSlash = func() (as as) {
+var alphaDigitUnderscore = func() (as as) {
 	for _, c := range "0123456789_" {
 		as[c/32] |= 1 << (c % 32)
 	}
-	for c := 'A'; c < 'Z'; c++ {
+	for c := 'A'; c <= 'Z'; c++ {
 		as[c/32] |= 1 << (c % 32)
 	}
-	for c := 'a'; c < 'z'; c++ {
+	for c := 'a'; c <= 'z'; c++ {
 		as[c/32] |= 1 << (c % 32)
 	}
 	return
 }()
 
+// scanMemberName returns the length of the member name shorthand at the
+// start of s, see section 2.5.1.1 of RFC 9535.
+func scanMemberName(s string) int {
+	if len(s) == 0 || s[0] >= '0' && s[0] <= '9' {
+		return 0
+	}
+	for i, r := range s {
+		switch {
+		case r < utf8.RuneSelf && alphaDigitUnderscore.c(byte(r)):
+		case r >= utf8.RuneSelf && r != utf8.RuneError:
+		default:
+			return i
+		}
+	}
+	return len(s)
+}
+
 func parseJSONPathBracket(b string) (end int, ret selector, err error) {
 	var segs = []selector{nil}[:0]
 	for end < len(b) {
@@ -149,53 +271,24 @@ func parseJSONPathBracket(b string) (end int, ret selector, err error) {
 			segs = append(segs, wildcardSelector{})
 			end++
 		case '\'', '"':
-			endStr, strEsc := end+1, false
-			for endStr < len(b) && (b[endStr] != b[end] || strEsc) {
-				if b[endStr] == '\\' {
-					strEsc = !strEsc
-				} else {
-					strEsc = false
-				}
-				endStr++
-			}
-			if b[endStr] != b[end] {
-				return end, nil, fmt.Errorf("%w: invalid name selector", ErrInvalidJsonpath)
-			}
-			endStr++
-			str := []byte(b[end:endStr])[:endStr-end]
-			if str[0] == '\'' { // make sure that unquote will not complain about unescaped quotes
-				str[0], str[len(str)-1] = '"', '"'
+			n, name, errStr := parseStringLiteral(b[end:])
+			if errStr != nil {
+				return end, nil, fmt.Errorf("%w, invalid name selector", errStr)
 			}
-			unquoted, ok := unquote(str)
-			if !ok {
-				return end, nil, fmt.Errorf("%w: invalid name selector, unquote error", ErrInvalidJsonpath)
-			}
-			segs = append(segs, nameSelector(unquoted))
-			end = endStr
+			segs = append(segs, nameSelector(name))
+			end += n
 		case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9', '-', ':':
+			var nums [3]int
+			var has [3]bool
 			cnt := 0
-			var nums = [3]int{0, -1, 1}
-			var isdefault [3]bool
 			for {
-				numStop, num := end, 0
-				for numStop < len(b) && b[numStop] == '-' || b[numStop] >= '0' && b[numStop] <= '9' {
-					numStop++
-				}
-				if numStop == end {
-					isdefault[cnt] = true
-				} else if num, err = strconv.Atoi(b[end:numStop]); err != nil {
-					return
-				} else {
-					nums[cnt] = num
-					if cnt == 2 && num < 0 {
-						if isdefault[0] {
-							nums[0] = -1
-						}
-						if isdefault[1] {
-							nums[1] = minInt
-						}
+				if end < len(b) && (b[end] == '-' || b[end] >= '0' && b[end] <= '9') {
+					n, num, errInt := parseInt(b[end:])
+					if errInt != nil {
+						return end, nil, errInt
 					}
-					end = numStop
+					nums[cnt], has[cnt] = num, true
+					end += n
 				}
 				for end < len(b) && emptyChar.c(b[end]) {
 					end++
@@ -208,15 +301,22 @@ func parseJSONPathBracket(b string) (end int, ret selector, err error) {
 					end++
 				}
 			}
-			switch cnt {
-			case 0:
+			if cnt == 0 {
 				segs = append(segs, indexSelector(nums[0]))
-			case 1, 2:
-				segs = append(segs, &arrSliceSelector{nums[0], nums[1], nums[2]})
+			} else {
+				sel := &arrSliceSelector{start: nums[0], end: nums[1], step: 1, hasStart: has[0], hasEnd: has[1]}
+				if has[2] {
+					sel.step = nums[2]
+				}
+				segs = append(segs, sel)
 			}
 		case '?':
-			err = fmt.Errorf("%w: filter selector is currently unsupported", ErrInvalidJsonpath)
-			return
+			n, filter, errFilter := parseFilter(b[end+1:])
+			if errFilter != nil {
+				return end, nil, errFilter
+			}
+			segs = append(segs, filter)
+			end += 1 + n
 		default:
 			err = ErrInvalidJsonpath
 			return
@@ -247,62 +347,135 @@ func parseJSONPathBracket(b string) (end int, ret selector, err error) {
 	return
 }
 
+// parseInt parses an integer of index and slice selectors at the start of
+// b, which must be within the range of I-JSON, see section 2.1 of RFC 9535.
+func parseInt(b string) (n int, num int, err error) {
+	if n < len(b) && b[n] == '-' {
+		n++
+	}
+	digits := n
+	for n < len(b) && b[n] >= '0' && b[n] <= '9' {
+		n++
+	}
+	switch {
+	case n == digits:
+		return n, 0, fmt.Errorf("%w: expected integer", ErrInvalidJsonpath)
+	case b[digits] == '0' && (n-digits > 1 || digits > 0):
+		return n, 0, fmt.Errorf("%w: invalid integer %s", ErrInvalidJsonpath, b[:n])
+	}
+	v, errConv := strconv.ParseInt(b[:n], 10, 64)
+	if errConv != nil || v > 1<<53-1 || v < -(1<<53-1) {
+		return n, 0, fmt.Errorf("%w: integer %s out of range", ErrInvalidJsonpath, b[:n])
+	}
+	return n, int(v), nil
+}
+
 func parseJSONPath(path string) ([]selector, error) {
 	if !strings.HasPrefix(path, "$") {
 		return nil, ErrInvalidJsonpath
 	}
-	var selectors []selector
-	path = path[1:]
-	for len(path) > 1 {
-		switch path[0] {
-		case '.':
-			switch path[1] {
-			case '.':
-				selectors = append(selectors, recursive)
-				if len(path) > 3 && path[2] == '[' {
-					path = path[2:]
-				} else {
-					path = path[1:]
-				}
-			case '*':
-				selectors = append(selectors, wildcardSelector{})
-				path = path[2:]
-			default:
-				idx := strings.IndexFunc(path[1:], func(r rune) bool {
-					if r < utf8.RuneSelf && alphaDigitSlash.c(byte(r)) {
-						return false
-					}
-					if r >= utf8.RuneSelf && r != utf8.RuneError {
-						return false
-					}
-					return true
-				})
-				if idx == -1 {
-					idx = len(path) - 1
-				}
-				selectors = append(selectors, nameSelector(path[1:idx+1]))
-				path = path[idx+1:]
-			}
-		case '[':
+	selectors, rest, err := parseSegments(path[1:])
+	if err == nil && len(rest) != 0 {
+		err = ErrInvalidJsonpath
+	}
+	return selectors, err
+}
+
+// parseSegments parses the segments following a root or current node
+// identifier, stopping at the first byte that doesn't start a segment.
+// Whitespace is allowed before each segment.
+func parseSegments(path string) (selectors []selector, rest string, err error) {
+	for {
+		i := 0
+		for i < len(path) && emptyChar.c(path[i]) {
+			i++
+		}
+		if i == len(path) || path[i] != '.' && path[i] != '[' {
+			return selectors, path, nil
+		}
+		path = path[i:]
+		child := false // .name or .*, which can't be followed by brackets
+		switch {
+		case strings.HasPrefix(path, ".."):
+			selectors = append(selectors, recursive)
+			path = path[2:]
+		case path[0] == '.':
+			path, child = path[1:], true
+		}
+		if !child && strings.HasPrefix(path, "[") {
 			end, ret, err := parseJSONPathBracket(path[1:])
 			if err != nil {
-				return selectors, err
+				return selectors, path, err
 			}
 			selectors = append(selectors, ret)
 			path = path[end+1:]
-		default:
-			return selectors, ErrInvalidJsonpath
+			continue
 		}
+		if strings.HasPrefix(path, "*") {
+			selectors = append(selectors, wildcardSelector{})
+			path = path[1:]
+			continue
+		}
+		n := scanMemberName(path)
+		if n == 0 {
+			return selectors, path, fmt.Errorf("%w: invalid member name shorthand", ErrInvalidJsonpath)
+		}
+		selectors = append(selectors, nameSelector(path[:n]))
+		path = path[n:]
 	}
-	if len(path) != 0 {
-		return selectors, ErrInvalidJsonpath
+}
+
+// appendNormalizedName appends name as a normalized path segment, see
+// section 2.7 of RFC 9535.
+func appendNormalizedName(dst []byte, name string) []byte {
+	return append(appendSingleQuoted(append(dst, '['), name), ']')
+}
+
+// appendSingleQuoted appends name as a single quoted string literal, escaped
+// as required in normalized paths.
+func appendSingleQuoted(dst []byte, name string) []byte {
+	const hex = "0123456789abcdef"
+	dst = append(dst, '\'')
+	for i := 0; i < len(name); i++ {
+		switch c := name[i]; c {
+		case '\b':
+			dst = append(dst, '\\', 'b')
+		case '\f':
+			dst = append(dst, '\\', 'f')
+		case '\n':
+			dst = append(dst, '\\', 'n')
+		case '\r':
+			dst = append(dst, '\\', 'r')
+		case '\t':
+			dst = append(dst, '\\', 't')
+		case '\'', '\\':
+			dst = append(dst, '\\', c)
+		default:
+			if c < 0x20 {
+				dst = append(dst, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xf])
+			} else {
+				dst = append(dst, c)
+			}
+		}
 	}
-	return selectors, nil
+	return append(dst, '\'')
+}
+
+// appendNormalizedKey appends the quoted object key as a normalized path segment.
+func appendNormalizedKey(dst []byte, key []byte) []byte {
+	name, _ := unquote(key)
+	return appendNormalizedName(dst, name)
+}
+
+func appendNormalizedIndex(dst []byte, idx int) []byte {
+	return append(strconv.AppendInt(append(dst, '['), int64(idx), 10), ']')
 }
 
 type selector interface {
 	SelectArr(idx int, iter *Iterator) bool
 	SelectObj(key *Token, iter *Iterator) bool
+	// appendTo appends the selector in normalized form, without brackets
+	appendTo(dst []byte) []byte
 }
 
 type combineSelector []selector
@@ -323,6 +496,15 @@ func (n combineSelector) SelectObj(key *Token, iter *Iterator) bool {
 	}
 	return false
 }
+func (n combineSelector) appendTo(dst []byte) []byte {
+	for i, n := range n {
+		if i != 0 {
+			dst = append(dst, ',')
+		}
+		dst = n.appendTo(dst)
+	}
+	return dst
+}
 
 type nameSelector string
 
@@ -330,7 +512,10 @@ func (n nameSelector) SelectArr(idx int, iter *Iterator) bool {
 	return false
 }
 func (n nameSelector) SelectObj(key *Token, iter *Iterator) bool {
-	return key.EqualString(string(n))
+	return iter.EqualString(key, string(n))
+}
+func (n nameSelector) appendTo(dst []byte) []byte {
+	return appendSingleQuoted(dst, string(n))
 }
 
 type indexSelector int
@@ -341,22 +526,94 @@ func (i indexSelector) SelectArr(idx int, iter *Iterator) bool {
 func (i indexSelector) SelectObj(key *Token, iter *Iterator) bool {
 	return false
 }
+func (i indexSelector) appendTo(dst []byte) []byte {
+	return strconv.AppendInt(dst, int64(i), 10)
+}
 
 // arrSliceSelector represents a selector for array slices
-type arrSliceSelector struct{ start, end, step int }
+type arrSliceSelector struct {
+	start, end, step int
+	hasStart, hasEnd bool // start and end are omitted otherwise
+}
 
+// SelectArr selects elements without knowing the length of the array, which
+// works only if needsLen reports false.
 func (s *arrSliceSelector) SelectArr(idx int, iter *Iterator) bool {
-	if s.step < 0 { // currently only positive step is supported
-		return false
-	}
-	if idx < s.start || s.end != -1 && idx >= s.end {
+	if s.step <= 0 || idx < s.start || s.hasEnd && idx >= s.end {
 		return false
 	}
 	return (idx-s.start)%s.step == 0
 }
+
+// needsLen reports whether the length of the array is needed to select
+// its elements. A step of 0 selects nothing, whatever the length.
+func (s *arrSliceSelector) needsLen() bool {
+	return s.step < 0 || s.step > 0 && (s.start < 0 || s.hasEnd && s.end < 0)
+}
+
+// bounds returns the lower and upper bounds of the slice in an array of
+// length n, see section 2.3.4.2.2 of RFC 9535. Selected indexes are in
+// [lower, upper) if step is positive, or (lower, upper] if it's negative.
+func (s *arrSliceSelector) bounds(n int) (lower, upper int) {
+	start, end := s.start, s.end
+	if !s.hasStart && s.step < 0 {
+		start = n - 1
+	}
+	if !s.hasEnd {
+		if end = n; s.step < 0 {
+			end = -n - 1
+		}
+	}
+	if start < 0 {
+		start += n
+	}
+	if end < 0 {
+		end += n
+	}
+	if s.step >= 0 {
+		return clamp(start, 0, n), clamp(end, 0, n)
+	}
+	return clamp(end, -1, n-1), clamp(start, -1, n-1)
+}
+
+// contains reports whether the slice selects idx in an array of length n.
+func (s *arrSliceSelector) contains(idx, n int) bool {
+	lower, upper := s.bounds(n)
+	switch {
+	case s.step > 0:
+		return idx >= lower && idx < upper && (idx-lower)%s.step == 0
+	case s.step < 0:
+		return idx <= upper && idx > lower && (upper-idx)%s.step == 0
+	}
+	return false
+}
+
+func clamp(i, lo, hi int) int {
+	if i < lo {
+		return lo
+	}
+	if i > hi {
+		return hi
+	}
+	return i
+}
+
 func (i *arrSliceSelector) SelectObj(key *Token, iter *Iterator) bool {
 	return false
 }
+func (s *arrSliceSelector) appendTo(dst []byte) []byte {
+	if s.hasStart {
+		dst = strconv.AppendInt(dst, int64(s.start), 10)
+	}
+	dst = append(dst, ':')
+	if s.hasEnd {
+		dst = strconv.AppendInt(dst, int64(s.end), 10)
+	}
+	if s.step != 1 {
+		dst = strconv.AppendInt(append(dst, ':'), int64(s.step), 10)
+	}
+	return dst
+}
 
 type wildcardSelector struct{}
 
@@ -366,6 +623,9 @@ func (wildcardSelector) SelectArr(idx int, iter *Iterator) bool {
 func (wildcardSelector) SelectObj(key *Token, iter *Iterator) bool {
 	return true
 }
+func (wildcardSelector) appendTo(dst []byte) []byte {
+	return append(dst, '*')
+}
 
 type recursiveSelector struct{}
 
@@ -377,3 +637,6 @@ func (recursiveSelector) SelectArr(idx int, iter *Iterator) bool {
 func (recursiveSelector) SelectObj(key *Token, iter *Iterator) bool {
 	return true
 }
+func (recursiveSelector) appendTo(dst []byte) []byte {
+	return append(dst, '.', '.')
+}
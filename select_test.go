@@ -2,6 +2,11 @@ package jsontk
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -163,3 +168,268 @@ func TestSelect(t *testing.T) {
 		}
 	})
 }
+
+func TestSelectSlice(t *testing.T) {
+	data := []byte(`[0, 1, 2, 3, 4, 5, 6, 7, 8, 9]`)
+	for path, want := range map[string]string{
+		`$[1:3]`:               "1 2",
+		`$[5:]`:                "5 6 7 8 9",
+		`$[1:5:2]`:             "1 3",
+		`$[5:1:-2]`:            "5 3",
+		`$[::-1]`:              "9 8 7 6 5 4 3 2 1 0",
+		`$[:]`:                 "0 1 2 3 4 5 6 7 8 9",
+		`$[::]`:                "0 1 2 3 4 5 6 7 8 9",
+		`$[1:5:0]`:             "",
+		`$[-1::0]`:             "",
+		`$[0:-1:0]`:            "",
+		`$[:-1:0]`:             "",
+		`$[-5:]`:               "5 6 7 8 9",
+		`$[-5:-2]`:             "5 6 7",
+		`$[0:-1]`:              "0 1 2 3 4 5 6 7 8",
+		`$[:-3:-1]`:            "9 8",
+		`$[10:5:-1]`:           "9 8 7 6",
+		`$[-100:100:3]`:        "0 3 6 9",
+		`$[3:-100:-3]`:         "3 0",
+		`$[3:1]`:               "",
+		`$[ 1 : 3 : 1 ]`:       "1 2",
+		`$[-1:-11:-4]`:         "9 5 1",
+		`$[::-3][::-1]`:        "",
+		`$[9007199254740991:]`: "",
+	} {
+		var got []string
+		var iter Iterator
+		iter.Reset(data)
+		if err := iter.Select(path, func(iter *Iterator) {
+			var tk Token
+			got = append(got, string(iter.NextToken(&tk).Value))
+		}); err != nil {
+			t.Errorf("%s: %v", path, err)
+		}
+		if strings.Join(got, " ") != want {
+			t.Errorf("%s: got %q, want %q", path, got, want)
+		}
+	}
+	for _, path := range []string{
+		`$[01:]`, `$[-0]`, `$[1:2:3:4]`, `$[1.0:]`, `$[9007199254740992:]`, `$[-:]`, `$[1 2]`,
+	} {
+		if _, err := parseJSONPath(path); !errors.Is(err, ErrInvalidJsonpath) {
+			t.Errorf("%s should be invalid, got %v", path, err)
+		}
+	}
+}
+
+func TestSelectFilter(t *testing.T) {
+	data := []byte(`{"store": [
+		{"price": 5, "tags": ["x", "y"], "title": "a"},
+		{"price": 15, "tags": ["x"], "title": "b"},
+		{"price": 8, "tags": ["z"], "title": "c", "isbn": null},
+		{"price": 8.0, "tags": [], "title": "d", "extra": {"k": [1, 2]}}
+	], "limit": 8}`)
+	cases := []struct {
+		name, path string
+		expt       Expectation
+	}{
+		{"Comparison", `$.store[?@.price < 10 && @.tags[0] == 'x'].title`, b(`"a"`)},
+		{"Existence", `$.store[?@.isbn].title`, b(`"c"`)},
+		{"NotExistence", `$.store[?!@.extra].title`, b(`"a"`, `"b"`, `"c"`)},
+		{"Parentheses", `$.store[?(@.price > 10 || @.title == "a") && @.tags].title`, b(`"a"`, `"b"`)},
+		{"RootQuery", `$.store[?@.price == $.limit].title`, b(`"c"`, `"d"`)},
+		{"Structured", `$.store[?@.extra.k == $.store[3].extra.k].title`, b(`"d"`)},
+		{"Literal", `$.store[?@.tags == null || @.isbn == null].title`, b(`"c"`)},
+		{"Strings", `$.store[*].title[?@ >= 'c']`, nil},
+		{"StringsCompare", `$.store[?@.title >= 'c'].price`, b("8", "8.0")},
+		{"Descendant", `$..[?@[1] == 2]`, b("[1, 2]")},
+		{"Nested", `$.store[?@.tags[?@ == 'y']].title`, b(`"a"`)},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			expt := c.expt
+			var iter Iterator
+			iter.Reset(data)
+			err := iter.Select(c.path, func(iter *Iterator) {
+				_, i, l := iter.Skip()
+				if v, ok := expt.Next(iter.data[i : i+l]); !ok {
+					t.Errorf("result mismatch, expected %s, got %s", string(v), string(iter.data[i:i+l]))
+				}
+			})
+			if err != nil {
+				t.Error(err)
+			}
+			if _, ok := expt.Next(nil); !ok {
+				t.Errorf("didn't match all expectations, %d remaining", len(expt))
+			}
+		})
+	}
+	for _, path := range []string{
+		`$[?@.* == 1]`, `$[?1]`, `$[?@.a == 01]`, `$[?@.a == 'x]`, `$[?(@.a]`, `$[?@.a === 1]`, `$[?!1 == 1]`,
+	} {
+		if _, err := parseJSONPath(path); !errors.Is(err, ErrInvalidJsonpath) {
+			t.Errorf("%s should be rejected, got %v", path, err)
+		}
+	}
+}
+
+func TestSelectFunctions(t *testing.T) {
+	err := RegisterFunc("starts_with", Func{
+		Params: []FuncType{ValueType, ValueType},
+		Result: LogicalType,
+		Call: func(args []FuncValue) FuncValue {
+			s, p := args[0].Value, args[1].Value
+			return FuncValue{Logical: s.Type == STRING && p.Type == STRING && strings.HasPrefix(s.String(), p.String())}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if RegisterFunc("StartsWith", Func{Call: func([]FuncValue) FuncValue { return FuncValue{} }}) == nil {
+		t.Error("invalid function name should be rejected")
+	}
+	data := []byte(`[
+		{"a": "abc", "b": [1, 2, 3]},
+		{"a": "a\nc", "b": {"x": 1}},
+		{"a": "xabc", "b": "é"},
+		{"a": "ab", "c": {"b": 2}}
+	]`)
+	cases := []struct {
+		name, path string
+		expt       Expectation
+	}{
+		{"Length", `$[?length(@.b) == 1].a`, b(`"a\nc"`, `"xabc"`)},
+		{"Count", `$[?count(@.*) == 2].a`, b(`"abc"`, `"a\nc"`, `"xabc"`, `"ab"`)},
+		{"CountDescendants", `$[?count(@..b) > 1].a`, nil},
+		{"Match", `$[?match(@.a, 'a.c')].a`, b(`"abc"`)},
+		{"Search", `$[?search(@.a, '[a-b]c')].a`, b(`"abc"`, `"xabc"`)},
+		{"Value", `$[?value(@..b) == 2].a`, b(`"ab"`)},
+		{"Nested", `$[?length(value(@.b)) >= 3].a`, b(`"abc"`)},
+		{"Custom", `$[?starts_with(@.a, 'ab')].a`, b(`"abc"`, `"ab"`)},
+		{"LogicalArg", `$[?starts_with(@.a, 'ab') && !search(@.a, 'c')].a`, b(`"ab"`)},
+		{"PatternFromDocument", `$[?search(@.a, $[3].a)].a`, b(`"abc"`, `"xabc"`, `"ab"`)},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			expt := c.expt
+			var iter Iterator
+			iter.Reset(data)
+			err := iter.Select(c.path, func(iter *Iterator) {
+				_, i, l := iter.Skip()
+				if v, ok := expt.Next(iter.data[i : i+l]); !ok {
+					t.Errorf("result mismatch, expected %s, got %s", string(v), string(iter.data[i:i+l]))
+				}
+			})
+			if err != nil {
+				t.Error(err)
+			}
+			if _, ok := expt.Next(nil); !ok {
+				t.Errorf("didn't match all expectations, %d remaining", len(expt))
+			}
+		})
+	}
+	for _, path := range []string{
+		`$[?length(@.*) > 1]`, `$[?length(@.a)]`, `$[?count(1) == 1]`, `$[?match(@.a)]`,
+		`$[?match(@.a, 'x') == true]`, `$[?unknown(@.a)]`, `$[?length (@.a) == 1]`,
+	} {
+		if _, err := parseJSONPath(path); !errors.Is(err, ErrInvalidJsonpath) {
+			t.Errorf("%s should be rejected, got %v", path, err)
+		}
+	}
+	if s := FuncType(7).String(); s != "FuncType(7)" {
+		t.Errorf("unexpected name %s", s)
+	}
+}
+
+// ctsSkips are the cases of the JSONPath Compliance Test Suite skipped on
+// purpose, by name, with the reason.
+var ctsSkips = map[string]string{}
+
+// TestCompliance runs every case of the JSONPath Compliance Test Suite,
+// vendored unchanged as testdata/cts/cts.json, see the README there, or
+// the cts.json named by JSONTK_CTS, e.g. in a checkout of the suite.
+func TestCompliance(t *testing.T) {
+	file := os.Getenv("JSONTK_CTS")
+	if file == "" {
+		file = "testdata/cts/cts.json"
+	}
+	if _, err := os.Stat(file); errors.Is(err, os.ErrNotExist) {
+		t.Skip(file + " is not vendored, see testdata/cts/README.md")
+	}
+	runCTS(t, file, ctsSkips)
+}
+
+// TestSelectCases runs the hand-written cases in testdata/jsonpath, which
+// are in the format of the compliance test suite.
+func TestSelectCases(t *testing.T) {
+	runCTS(t, "testdata/jsonpath/cases.json", nil)
+}
+
+// runCTS runs the cases in file, in the cts.json format of the JSONPath
+// Compliance Test Suite, except the ones in skips.
+func runCTS(t *testing.T, file string, skips map[string]string) {
+	f, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cts struct {
+		Tests []struct {
+			Name            string
+			Selector        string
+			Document        json.RawMessage
+			Result          []interface{}
+			Results         [][]interface{}
+			ResultPaths     []string   `json:"result_paths"`
+			ResultsPaths    [][]string `json:"results_paths"`
+			InvalidSelector bool       `json:"invalid_selector"`
+		}
+	}
+	if err := json.Unmarshal(f, &cts); err != nil {
+		t.Fatal(err)
+	}
+	ran := 0
+	for _, tc := range cts.Tests {
+		if _, ok := skips[tc.Name]; ok {
+			continue
+		}
+		ran++
+		if tc.InvalidSelector {
+			if _, err := parseJSONPath(tc.Selector); !errors.Is(err, ErrInvalidJsonpath) {
+				t.Errorf("%s: %q should be rejected, got %v", tc.Name, tc.Selector, err)
+			}
+			continue
+		}
+		var got []interface{}
+		var paths []string
+		var iter Iterator
+		iter.Reset(tc.Document)
+		if err := iter.SelectWithPath(tc.Selector, func(p NormalizedPath, iter *Iterator) {
+			_, i, l := iter.Skip()
+			var v interface{}
+			if err := json.Unmarshal(iter.data[i:i+l], &v); err != nil {
+				t.Errorf("%s: %v", tc.Name, err)
+			}
+			got, paths = append(got, v), append(paths, p.String())
+		}); err != nil {
+			t.Errorf("%s: %v", tc.Name, err)
+			continue
+		}
+		// cases with several valid orders of results list each of them
+		results, resultsPaths := tc.Results, tc.ResultsPaths
+		if results == nil {
+			results, resultsPaths = [][]interface{}{tc.Result}, [][]string{tc.ResultPaths}
+		}
+		matched := false
+		for i, want := range results {
+			if len(got) != len(want) || len(got) != 0 && !reflect.DeepEqual(got, want) {
+				continue
+			}
+			if i < len(resultsPaths) && resultsPaths[i] != nil && !reflect.DeepEqual(paths, resultsPaths[i]) {
+				continue
+			}
+			matched = true
+		}
+		if !matched {
+			t.Errorf("%s: %s got %v at %q, want %v at %q", tc.Name, tc.Selector, got, paths, results, resultsPaths)
+		}
+	}
+	if ran+len(skips) != len(cts.Tests) {
+		t.Errorf("%d cases skipped by name aren't in %s", ran+len(skips)-len(cts.Tests), file)
+	}
+}
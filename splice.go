@@ -0,0 +1,177 @@
+package jsontk
+
+import "sort"
+
+// container is an object or array located in the raw json, as read by
+// readContainer. Locations are offsets into the raw json.
+type container struct {
+	typ     TokenType // BEGIN_OBJECT or BEGIN_ARRAY
+	open    int       // location of the opening bracket
+	end     int       // location right after the closing bracket
+	members []member
+}
+
+// member is an object member or array element of a container.
+type member struct {
+	start  int // location of the key, or of the value for array elements
+	keyEnd int // location right after the key, same as start for array elements
+	loc    int // location of the value
+	end    int // location right after the value
+}
+
+func (m member) key(data []byte) *Token {
+	return &Token{Type: KEY, Value: data[m.start:m.keyEnd]}
+}
+
+// readContainer reads the object or array at loc with the options in cfg.
+func readContainer(data []byte, loc int, cfg *config) (c container, err error) {
+	var iter Iterator
+	iter.cfg = *cfg
+	iter.Reset(data)
+	iter.head = loc
+	c.typ = iter.Peek()
+	c.open = iter.head
+	elem := func() {
+		m := member{start: iter.keyAt, keyEnd: iter.keyAt + len(iter.key.Value)}
+		if c.typ == BEGIN_ARRAY {
+			iter.skipSpace()
+			m.start, m.keyEnd = iter.head, iter.head
+		}
+		_, m.loc, _ = iter.Skip()
+		m.end = iter.head
+		c.members = append(c.members, m)
+	}
+	switch c.typ {
+	case BEGIN_OBJECT:
+		err = iter.NextObject(func(*Token) bool { elem(); return true })
+	case BEGIN_ARRAY:
+		err = iter.NextArray(func(int) bool { elem(); return true })
+	default:
+		err = iter.fail(ErrUnexpectedToken, iter.head, false, "expected object or array", BEGIN_OBJECT, BEGIN_ARRAY)
+	}
+	c.end = iter.head
+	return c, err
+}
+
+// removeSpan returns the span of the i-th member together with exactly one
+// adjacent comma, so that removing it leaves valid json.
+func (c *container) removeSpan(i int) (start, end int) {
+	switch ms := c.members; {
+	case len(ms) == 1:
+		return ms[0].start, ms[0].end
+	case i < len(ms)-1:
+		return ms[i].start, ms[i+1].start
+	default:
+		return ms[i-1].end, ms[i].end
+	}
+}
+
+// insertAt returns where and what to insert, so that raw becomes the i-th
+// member of the container. raw is a "key": value pair for objects or a value
+// for arrays. Separators between existing members are reused, so that
+// inserted members are formatted like their siblings.
+func (c *container) insertAt(data []byte, i int, raw []byte) (at int, ins []byte) {
+	ms := c.members
+	if len(ms) == 0 {
+		return c.open + 1, raw
+	}
+	sep := c.sep(data, len(ms), true)
+	if i < len(ms) {
+		return ms[i].start, append(append(make([]byte, 0, len(raw)+len(sep)), raw...), sep...)
+	}
+	return ms[len(ms)-1].end, append(append(make([]byte, 0, len(raw)+len(sep)), sep...), raw...)
+}
+
+// sep returns the separator to be written before the i-th member when
+// rewriting the container, in which i is len(c.members) for new members.
+// written tells whether any member is written before it.
+func (c *container) sep(data []byte, i int, written bool) []byte {
+	switch ms := c.members; {
+	case !written && len(ms) != 0:
+		return data[c.open+1 : ms[0].start]
+	case !written:
+		return nil
+	case i > 0 && i < len(ms):
+		return data[ms[i-1].end:ms[i].start]
+	case len(ms) > 1:
+		return data[ms[0].end:ms[1].start]
+	case len(ms) == 1 && ms[0].start > c.open+1:
+		// e.g. ",\n\t" for `{\n\t"a": 1\n}`
+		return append([]byte{','}, data[c.open+1:ms[0].start]...)
+	case len(ms) == 1 && ms[0].keyEnd < ms[0].loc-1:
+		return []byte{',', ' '} // spaces around the colon, e.g. `{"a": 1}`
+	default:
+		return []byte{','}
+	}
+}
+
+// appendMember appends an object member, with the colon formatted like
+// those of the existing members.
+func (c *container) appendMember(dst, data []byte, key string, value []byte) []byte {
+	colon := []byte{':', ' '}
+	if len(c.members) != 0 {
+		colon = data[c.members[0].keyEnd:c.members[0].loc]
+	}
+	return append(append(appendQuoted(dst, key), colon...), value...)
+}
+
+// splice returns a copy of data with data[start:end] replaced by repl.
+func splice(data []byte, start, end int, repl []byte) []byte {
+	ret := make([]byte, 0, len(data)-(end-start)+len(repl))
+	return append(append(append(ret, data[:start]...), repl...), data[end:]...)
+}
+
+// edit replaces data[start:end] with repl.
+type edit struct {
+	start, end int
+	repl       []byte
+}
+
+// applyEdits appends data with the edits applied to dst, in a single pass.
+// The edits must be sorted and must not overlap.
+func applyEdits(dst, data []byte, edits []edit) []byte {
+	last := 0
+	for _, e := range edits {
+		dst = append(append(dst, data[last:e.start]...), e.repl...)
+		last = e.end
+	}
+	return append(dst, data[last:]...)
+}
+
+// removeEdits appends the edits removing members of the container at loc to
+// edits. Members whose value is at one of the sorted locations in locs are
+// removed together with exactly one adjacent comma, and members containing
+// any of the locations are processed recursively.
+func removeEdits(edits []edit, data []byte, loc int, locs []int, cfg *config) ([]edit, error) {
+	c, err := readContainer(data, loc, cfg)
+	if err != nil {
+		return edits, err
+	}
+	ms := c.members
+	run := -1 // first member of the run of removed members
+	for i, m := range ms {
+		lo, hi := sort.SearchInts(locs, m.loc), sort.SearchInts(locs, m.end)
+		if lo < hi && locs[lo] == m.loc {
+			if run < 0 {
+				run = i
+			}
+			continue
+		}
+		if run >= 0 {
+			edits = append(edits, edit{start: ms[run].start, end: m.start})
+			run = -1
+		}
+		if lo < hi {
+			if edits, err = removeEdits(edits, data, m.loc, locs[lo:hi], cfg); err != nil {
+				return edits, err
+			}
+		}
+	}
+	switch {
+	case run == 0:
+		edits = append(edits, edit{start: ms[0].start, end: ms[len(ms)-1].end})
+	case run > 0:
+		edits = append(edits, edit{start: ms[run-1].end, end: ms[len(ms)-1].end})
+	}
+	return edits, nil
+}
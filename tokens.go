@@ -77,6 +77,8 @@ func (j *Token) Number() json.Number {
 	return json.Number(j.Value)
 }
 
+// UnquoteBytes unquotes the string, keeping invalid UTF-8 as is, see
+// [Iterator.Unquote] to handle it as configured by [ValidUTF8].
 func (j *Token) UnquoteBytes() ([]byte, bool) {
 	return unquoteBytes(j.Value)
 }
@@ -40,6 +40,61 @@ func getu4(s []byte) (r rune) {
 	return
 }
 
+// unescapeU decodes the \uXXXX escape at the beginning of s, together with
+// the following one if they make a surrogate pair. Like encoding/json, lone
+// surrogates are decoded as U+FFFD. It returns the rune and the number of
+// bytes decoded, which is 0 if s doesn't start with a valid escape.
+func unescapeU(s []byte) (rune, int) {
+	if len(s) < 6 || s[0] != '\\' || s[1] != 'u' {
+		return -1, 0
+	}
+	r := getu4(s[2:6])
+	if r < 0 {
+		return -1, 0
+	}
+	if !utf16.IsSurrogate(r) {
+		return r, 6
+	}
+	if len(s) >= 12 && s[6] == '\\' && s[7] == 'u' {
+		if dec := utf16.DecodeRune(r, getu4(s[8:12])); dec != unicode.ReplacementChar {
+			return dec, 12
+		}
+	}
+	return unicode.ReplacementChar, 6
+}
+
+// appendValidUTF8 appends s to dst, with each byte of invalid UTF-8
+// sequences replaced by U+FFFD, like encoding/json.
+func appendValidUTF8(dst, s []byte) []byte {
+	for len(s) > 0 {
+		r, n := utf8.DecodeRune(s)
+		if r == utf8.RuneError && n == 1 {
+			dst = utf8.AppendRune(dst, utf8.RuneError)
+		} else {
+			dst = append(dst, s[:n]...)
+		}
+		s = s[n:]
+	}
+	return dst
+}
+
+// unquoteBytesUTF8 is like unquoteBytes, but handles invalid UTF-8 according
+// to mode.
+func unquoteBytesUTF8(s []byte, mode UTF8Mode) ([]byte, bool) {
+	t, ok := unquoteBytes(s)
+	switch {
+	case !ok || mode == UTF8PassThrough:
+		return t, ok
+	case mode == UTF8Reject:
+		if bad, _ := checkString(s, false, true); bad >= 0 {
+			return nil, false
+		}
+	case !utf8.Valid(t):
+		return appendValidUTF8(make([]byte, 0, len(t)+8), t), true
+	}
+	return t, true
+}
+
 // unquoteBytes unquotes json strings
 // it assumes that quote escape is always correctly handled, so it won't
 // complain about unescaped quotes ("te"st" -> te"st)
@@ -69,22 +124,11 @@ func unquoteBytes(s []byte) (t []byte, ok bool) {
 		case 0:
 			return
 		case 0xff:
-			if r+5 > len(s) {
-				return
-			}
-			rr := getu4(s[r+1 : r+5])
-			if rr < 0 {
+			rr, n := unescapeU(s[r-1:])
+			if n == 0 {
 				return
 			}
-			r += 5
-			if utf16.IsSurrogate(rr) {
-				if r+6 > len(s) || s[r] != '\\' || s[r+1] != 'u' {
-					rr = unicode.ReplacementChar
-				} else {
-					rr = utf16.DecodeRune(rr, getu4(s[r+2:r+6]))
-					r += 6
-				}
-			}
+			r += n - 1
 			w += utf8.EncodeRune(b[w:], rr)
 		}
 		if r == len(s) {
@@ -130,27 +174,14 @@ func unquotedEqual(s, d []byte) (equal bool) {
 		case 0xff:
 			drune, sz := utf8.DecodeRune(d[r:])
 			d = d[r+sz:]
-			if drune == utf8.RuneError || r+6 > len(s) {
+			if drune == utf8.RuneError && sz != 3 { // not an encoded U+FFFD
 				return
 			}
-			srune := getu4(s[r+2 : r+6])
-			if srune < 0 {
-				return
-			}
-			r += 6
-			if utf16.IsSurrogate(srune) {
-				if r+6 > len(s) || s[r] != '\\' || s[r+1] != 'u' {
-					return
-				}
-				srune = utf16.DecodeRune(srune, getu4(s[r+2:r+6]))
-				if srune == unicode.ReplacementChar {
-					return
-				}
-				r += 6
-			}
-			if srune != drune {
+			srune, n := unescapeU(s[r:])
+			if n == 0 || srune != drune {
 				return
 			}
+			r += n
 		}
 		if r == len(s) {
 			return len(d) == 0
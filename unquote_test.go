@@ -3,6 +3,7 @@ package jsontk
 import (
 	"bytes"
 	"encoding/json"
+	"strings"
 	"testing"
 	"unicode/utf8"
 )
@@ -63,6 +64,36 @@ func TestUnquotedEqual(t *testing.T) {
 	}
 }
 
+func TestUnquoteUTF8(t *testing.T) {
+	for _, tc := range []struct {
+		in                   string
+		pass, replace, eqStr string
+	}{
+		{`"abc"`, "abc", "abc", "abc"},
+		{"\"a\xffb\"", "a\xffb", "a\ufffdb", "a\xffb"},
+		{"\"\xe4\xb8\"", "\xe4\xb8", "\ufffd\ufffd", "\xe4\xb8"},
+		{`"\uD83D\uDE00"`, "\U0001F600", "\U0001F600", "\U0001F600"},
+		{`"\uD800"`, "\ufffd", "\ufffd", "\ufffd"},
+		{`"\uD800\u0041"`, "\ufffdA", "\ufffdA", "\ufffdA"},
+		{`"\uDC00\uD800x"`, "\ufffd\ufffdx", "\ufffd\ufffdx", "\ufffd\ufffdx"},
+	} {
+		s := []byte(tc.in)
+		if out, ok := unquoteBytesUTF8(s, UTF8PassThrough); !ok || string(out) != tc.pass {
+			t.Errorf("%s: pass-through got %q", tc.in, out)
+		}
+		if out, ok := unquoteBytesUTF8(s, UTF8Replace); !ok || string(out) != tc.replace {
+			t.Errorf("%s: replace got %q", tc.in, out)
+		}
+		_, ok := unquoteBytesUTF8(s, UTF8Reject)
+		if ok != (tc.pass == tc.replace && !strings.ContainsRune(tc.pass, utf8.RuneError)) {
+			t.Errorf("%s: reject got %v", tc.in, ok)
+		}
+		if !unquotedEqualStr(s, tc.eqStr) {
+			t.Errorf("%s: not equal to %q", tc.in, tc.eqStr)
+		}
+	}
+}
+
 func FuzzUnquotedEqual(f *testing.F) {
 	f.Add([]byte(`""`))
 	f.Add([]byte(`"abc"`))
@@ -76,6 +107,8 @@ func FuzzUnquotedEqual(f *testing.F) {
 	f.Add([]byte(`"\u12"`))
 	f.Add([]byte(`"\\"`))
 	f.Add([]byte(`"阿巴阿巴"`))
+	f.Add([]byte("\"\xff\xe4\xb8\""))
+	f.Add([]byte(`"\uDC00\uD800x"`))
 
 	f.Fuzz(func(t *testing.T, s []byte) {
 		if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
@@ -84,13 +117,11 @@ func FuzzUnquotedEqual(f *testing.F) {
 		out, ok := unquoteBytes(s)
 		if ok {
 			_, _ = unquoteBytes(append([]byte{}, s...))
-			if bytes.ContainsRune(out, utf8.RuneError) {
-				return
-			}
 			if !unquotedEqual(s, out) {
 				t.Fatalf("inconsistent:\ninput: %q\nunquoted: %q", s, out)
 			}
 		}
+		out, ok = unquoteBytesUTF8(s, UTF8Replace)
 		var std string
 		if json.Unmarshal(s, &std) == nil && (!ok || string(out) != std) {
 			t.Fatalf("mismatch against std: %q", s)
@@ -1,53 +1,151 @@
 package jsontk
 
-import (
-	"encoding/json"
-)
+// Validate checks that data is a single json value strictly conforming to
+// RFC 8259, see [Iterator.Validate].
+func Validate(data []byte, opts ...Option) error {
+	var iter Iterator
+	iter.Configure(opts...)
+	iter.Reset(data)
+	return iter.Validate()
+}
 
+// Validate checks that the rest of the input is a single json value strictly
+// conforming to RFC 8259, including number grammar, string escapes, control
+// characters and UTF-8, regardless of the options of the iterator, except
+// that duplicate keys are rejected with [DisallowDuplicateKeys].
 func (iter *Iterator) Validate() error {
+	if iter.r == nil && iter.Error == nil && !iter.cfg.uniqueKeys && valid(iter.data[iter.head:], &iter.cfg) {
+		iter.head = len(iter.data)
+		return nil
+	}
+	// read the input again with the iterator to locate the error
+	cfg := iter.cfg
+	iter.cfg.apply([]Option{Strict(), ValidUTF8(UTF8Reject)})
+	defer func() { iter.cfg = cfg }()
 	if err := walk(iter); err != nil {
 		return err
 	}
-	if iter.Peek() != INVALID {
-		return ErrUnexpectedToken
+	iter.skipSpace()
+	if iter.head < len(iter.data) {
+		return iter.fail(ErrUnexpectedToken, iter.head, false, "expected EOF")
 	}
 	return nil
 }
 
+// walk reads the next value, in which tokens are checked by the tokenizer.
 func walk(iter *Iterator) (err error) {
-	switch iter.Peek() {
+	switch typ := iter.Peek(); typ {
 	case END_OBJECT, END_ARRAY:
-		return ErrStandardViolation
+		return iter.fail(ErrStandardViolation, iter.head, false, "unexpected "+typ.String())
 	case BEGIN_OBJECT:
-		return iter.NextObject(func(key *Token) bool {
-			if !json.Valid(key.Value) {
-				err = ErrStandardViolation
-				return false
-			}
+		return iter.NextObject(func(*Token) bool {
 			iter.Error = walk(iter)
 			return iter.Error == nil
 		})
 	case BEGIN_ARRAY:
-		return iter.NextArray(func(idx int) bool {
+		return iter.NextArray(func(int) bool {
 			iter.Error = walk(iter)
 			return iter.Error == nil
 		})
-	case STRING:
-		var tk Token
-		iter.NextToken(&tk)
-		if !json.Valid(tk.Value) {
-			return ErrStandardViolation
-		}
-	case NUMBER:
-		var tk Token
-		iter.NextToken(&tk)
-		_, err := tk.Number().Float64()
-		return err
-	case INVALID:
-		_, _, err := next(iter.data, iter.head)
-		return err
 	default:
-		iter.Skip()
+		iter.Next()
+		return iter.Error
+	}
+}
+
+// valid reports whether s is a single json value strictly conforming to
+// RFC 8259 and within the limits. It's the fast path of Validate for inputs
+// in memory, and tells nothing about where s is malformed.
+func valid(s []byte, cfg *config) bool {
+	l := &cfg.limits
+	maxDepth, tokens := cfg.maxDepth(), 0
+	stack := make([]frame, 0, 64) // containers being read
+	i := skip(s, 0)
+	for {
+		if i < 0 || i >= len(s) {
+			return false
+		}
+		if tokens++; l.MaxTokens > 0 && tokens > l.MaxTokens {
+			return false
+		}
+		switch c, start := s[i], i; c {
+		case '{', '[':
+			if len(stack) >= maxDepth {
+				return false
+			}
+			if i = skip(s, i+1); i < len(s) && s[i] == c+2 { // {} or []
+				i++
+				break
+			}
+			if stack = append(stack, frame{open: c, members: 1}); c == '{' {
+				i = validKey(s, i, l)
+				tokens++
+			}
+			continue
+		case '"':
+			if i, _, _ = scanString(s, i, true, true); l.MaxStringLength > 0 && i-start > l.MaxStringLength {
+				return false
+			}
+		case 't':
+			i = validLiteral(s, i, "true")
+		case 'f':
+			i = validLiteral(s, i, "false")
+		case 'n':
+			i = validLiteral(s, i, "null")
+		default:
+			var ok bool
+			if i, ok = scanNumber(s, i); !ok || l.MaxNumberLength > 0 && i-start > l.MaxNumberLength {
+				return false
+			}
+		}
+		// close the containers ending here, until a comma or EOF
+		for i >= 0 {
+			if i = skip(s, i); len(stack) == 0 {
+				return i == len(s)
+			}
+			if i == len(s) {
+				return false
+			}
+			top := &stack[len(stack)-1]
+			if s[i] == top.open+2 {
+				stack = stack[:len(stack)-1]
+				i++
+				continue
+			}
+			if s[i] != ',' {
+				return false
+			}
+			if top.members++; l.MaxMembers > 0 && top.members > l.MaxMembers {
+				return false
+			}
+			if i = skip(s, i+1); top.open == '{' {
+				i = validKey(s, i, l)
+				tokens++
+			}
+			break
+		}
+	}
+}
+
+// validKey reads an object key and the colon after it at i, returning the
+// location of the value, or -1 if they're malformed or the key is too long.
+func validKey(s []byte, i int, l *Limits) int {
+	if i >= len(s) || s[i] != '"' {
+		return -1
+	}
+	start := i
+	if i, _, _ = scanString(s, i, true, true); i < 0 || l.MaxStringLength > 0 && i-start > l.MaxStringLength {
+		return -1
+	}
+	if i = skip(s, i); i == len(s) || s[i] != ':' {
+		return -1
+	}
+	return skip(s, i+1)
+}
+
+func validLiteral(s []byte, i int, lit string) int {
+	if len(s)-i < len(lit) || string(s[i:i+len(lit)]) != lit {
+		return -1
 	}
-	return
+	return i + len(lit)
 }
@@ -0,0 +1,83 @@
+package jsontk
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	for _, data := range []string{
+		`0`, `-0`, `-0.0e-0`, `1E+2`, `123.456e789`, `"\u0000😀\"\\\/\b\f\n\r\t"`,
+		` [ ] `, `{}`, `{"a":[{"b":null,"c":[true,false]}],"":""}`, "\"é中\U0001F600\"",
+	} {
+		if err := Validate([]byte(data)); err != nil {
+			t.Errorf("%s: unexpected error %v", data, err)
+		}
+	}
+	for _, data := range []string{
+		``, ` `, `01`, `-`, `1.`, `.1`, `1e`, `1e+`, `+1`, `--1`, `0x1`, `NaN`, `1 2`,
+		`[1,]`, `{"a":1,}`, `[,1]`, `{"a"}`, `{1:1}`, `[1 2]`, `]`, `[}`, `{"a":1]`,
+		`"a`, "\"\t\"", `"\x"`, `"\u12"`, `"\'"`, `"\ud800"`, "\"\xff\"", "\"\xed\xa0\x80\"",
+		`tru`, `nul`, `True`, "\xef\xbb\xbf{}",
+	} {
+		if err := Validate([]byte(data)); err == nil || !errors.As(err, new(*SyntaxError)) {
+			t.Errorf("%q: expected syntax error, got %v", data, err)
+		}
+	}
+
+	var iter Iterator
+	iter.Configure(ValidUTF8(UTF8Replace))
+	iter.Reset([]byte(`["a", "\ud800"]`))
+	if iter.Validate() == nil {
+		t.Error("lone surrogate accepted")
+	}
+	iter.Reset([]byte(`["a", 1,]`))
+	if iter.Skip(); iter.Error != nil {
+		t.Errorf("options not restored: %v", iter.Error)
+	}
+}
+
+func TestValidateDatasets(t *testing.T) {
+	entries, _ := os.ReadDir("./testdata")
+	var iter Iterator
+	for _, ent := range entries {
+		if ent.IsDir() {
+			continue
+		}
+		data, _ := os.ReadFile(path.Join("./testdata", ent.Name()))
+		if err := Validate(data); (err == nil) != json.Valid(data) {
+			t.Errorf("%s: %v", ent.Name(), err)
+		}
+		allocs := testing.AllocsPerRun(10, func() {
+			iter.Reset(data)
+			iter.Validate()
+		})
+		if allocs != 0 {
+			t.Errorf("%s: %v allocations", ent.Name(), allocs)
+		}
+	}
+}
+
+func FuzzValidate(f *testing.F) {
+	for _, seed := range []string{
+		`{"a":[1,-0.5e+3,"é😀",{}],"b":{"c":null}}`, `[1,]`, `01`, `"\ud800"`,
+		"\"\xff\"", `{"a" 1}`, `[1 2]`, `[true,false,nul]`, `"\t"`,
+	} {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var iter Iterator
+		iter.ResetReader(bytes.NewReader(data)) // never takes the fast path
+		err := iter.Validate()
+		if fast := valid(data, &iter.cfg); fast != (err == nil) {
+			t.Fatalf("%q: fast path says %v, iterator says %v", data, fast, err)
+		}
+		if err == nil && !json.Valid(data) {
+			t.Fatalf("%q: accepted, but invalid for encoding/json", data)
+		}
+	})
+}
@@ -0,0 +1,148 @@
+package jsontk
+
+import "fmt"
+
+// Value is a json value backed by the bytes of the document it's parsed
+// from. Objects and arrays are only read when descended into, after which
+// the locations of their members are cached. A Value is not safe for
+// concurrent use, and methods on a nil *Value, e.g. a missing member,
+// behave as on an INVALID value.
+type Value struct {
+	typ  TokenType
+	raw  []byte
+	keys [][]byte // quoted keys of object members, in document order
+	vals []Value  // members, nil until read
+}
+
+// Parse checks that data is a single json value strictly conforming to
+// RFC 8259, as [Validate] does with the options, and returns it as a
+// [Value] whose objects and arrays are read lazily.
+func Parse(data []byte, opts ...Option) (*Value, error) {
+	if err := Validate(data, opts...); err != nil {
+		return nil, err
+	}
+	var iter Iterator
+	iter.Reset(data)
+	typ, loc, length := iter.Skip()
+	return &Value{typ: typ, raw: data[loc : loc+length : loc+length]}, nil
+}
+
+// Type returns the type of the value, which is BEGIN_OBJECT for objects
+// and BEGIN_ARRAY for arrays.
+func (v *Value) Type() TokenType {
+	if v == nil {
+		return INVALID
+	}
+	return v.typ
+}
+
+// Raw returns the value as in the document.
+func (v *Value) Raw() []byte {
+	if v == nil {
+		return nil
+	}
+	return v.raw
+}
+
+// Len returns the number of members of an object or array, or 0 for other
+// values.
+func (v *Value) Len() int {
+	return len(v.members())
+}
+
+// Keys returns the unquoted member names of an object in document order,
+// or nil for other values.
+func (v *Value) Keys() []string {
+	if v.Type() != BEGIN_OBJECT {
+		return nil
+	}
+	v.members()
+	keys := make([]string, len(v.keys))
+	for i, k := range v.keys {
+		u, _ := unquoteBytes(k)
+		keys[i] = string(u)
+	}
+	return keys
+}
+
+// Get returns the member of an object named k, the last one if there are
+// several as with encoding/json, or nil if there's none.
+func (v *Value) Get(k string) *Value {
+	if v.Type() != BEGIN_OBJECT {
+		return nil
+	}
+	vals := v.members()
+	for i := len(v.keys) - 1; i >= 0; i-- {
+		if unquotedEqualStr(v.keys[i], k) {
+			return &vals[i]
+		}
+	}
+	return nil
+}
+
+// Index returns the i-th element of an array, or nil if there's none.
+func (v *Value) Index(i int) *Value {
+	if v.Type() != BEGIN_ARRAY {
+		return nil
+	}
+	if vals := v.members(); i >= 0 && i < len(vals) {
+		return &vals[i]
+	}
+	return nil
+}
+
+// Float64 returns the value of a number.
+func (v *Value) Float64() (float64, error) {
+	if v.Type() != NUMBER {
+		return 0, fmt.Errorf("%w: %s is not a number", ErrUnexpectedToken, v.Type())
+	}
+	t := Token{Type: NUMBER, Value: v.raw}
+	return t.Number().Float64()
+}
+
+// String returns the unquoted value of a string.
+func (v *Value) String() (string, error) {
+	if v.Type() != STRING {
+		return "", fmt.Errorf("%w: %s is not a string", ErrUnexpectedToken, v.Type())
+	}
+	s, ok := unquoteBytes(v.raw)
+	if !ok {
+		return "", fmt.Errorf("%w: invalid string %s", ErrUnexpectedToken, v.raw)
+	}
+	return string(s), nil
+}
+
+// members reads the members of an object or array on first use, which
+// can't fail since the document is valid.
+func (v *Value) members() []Value {
+	if v == nil {
+		return nil
+	}
+	if v.vals != nil || v.typ != BEGIN_OBJECT && v.typ != BEGIN_ARRAY {
+		return v.vals
+	}
+	var iter Iterator
+	iter.Configure(WithLimits(Limits{MaxDepth: -1})) // checked by Parse
+	iter.Reset(v.raw)
+	v.vals = make([]Value, 0, 4)
+	if v.typ == BEGIN_OBJECT {
+		v.keys = make([][]byte, 0, 4)
+		iter.NextObject(func(key *Token) bool {
+			v.keys = append(v.keys, key.Value)
+			v.vals = append(v.vals, nextMember(&iter))
+			return true
+		})
+	} else {
+		iter.NextArray(func(int) bool {
+			v.vals = append(v.vals, nextMember(&iter))
+			return true
+		})
+	}
+	return v.vals
+}
+
+// nextMember reads the next member of the container being read by iter.
+func nextMember(iter *Iterator) Value {
+	typ, loc, length := iter.Skip()
+	return Value{typ: typ, raw: iter.data[loc : loc+length : loc+length]}
+}
@@ -0,0 +1,112 @@
+package jsontk
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+func TestValue(t *testing.T) {
+	v, err := Parse([]byte(` {"a": [1, {"b/c": "x\ty"}, null], "d": 2, "d": 3.5, "e": {}} `))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Type() != BEGIN_OBJECT || v.Len() != 4 || !reflect.DeepEqual(v.Keys(), []string{"a", "d", "d", "e"}) {
+		t.Errorf("unexpected object %s, keys %q", v.Raw(), v.Keys())
+	}
+	a := v.Get("a")
+	if a.Type() != BEGIN_ARRAY || a.Len() != 3 || a.Index(2).Type() != NULL || a.Index(3) != nil || a.Index(-1) != nil {
+		t.Errorf("unexpected array %s", a.Raw())
+	}
+	if s, err := a.Index(1).Get("b/c").String(); err != nil || s != "x\ty" {
+		t.Errorf("unexpected string %q, %v", s, err)
+	}
+	if f, err := v.Get("d").Float64(); err != nil || f != 3.5 {
+		t.Errorf("expected the last member, got %v, %v", f, err)
+	}
+	if e := v.Get("e"); e.Len() != 0 || e.Keys() == nil || e.Index(0) != nil {
+		t.Errorf("unexpected empty object %s", e.Raw())
+	}
+
+	// missing members
+	missing := v.Get("x").Get("y").Index(0)
+	if missing != nil || missing.Type() != INVALID || missing.Len() != 0 || missing.Keys() != nil || missing.Raw() != nil {
+		t.Errorf("unexpected missing value %v", missing)
+	}
+	if _, err := missing.Float64(); !errors.Is(err, ErrUnexpectedToken) {
+		t.Errorf("expected error reading a missing number, got %v", err)
+	}
+	if _, err := v.Get("d").String(); !errors.Is(err, ErrUnexpectedToken) {
+		t.Errorf("expected error reading a number as a string, got %v", err)
+	}
+	if a.Get("0") != nil || v.Index(0) != nil {
+		t.Error("expected no members by the wrong kind of selector")
+	}
+
+	for _, data := range []string{``, `[1,]`, `{"a" 1}`, `[1] 2`, `"\x"`} {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("%s: expected error", data)
+		}
+	}
+	if _, err := Parse([]byte(`{"a":1,"a":2}`), DisallowDuplicateKeys()); !errors.Is(err, ErrDuplicateKey) {
+		t.Errorf("expected duplicate key error, got %v", err)
+	}
+}
+
+// toInterface converts v like encoding/json does when unmarshaling into
+// an interface{}.
+func toInterface(t *testing.T, v *Value) interface{} {
+	switch v.Type() {
+	case BEGIN_OBJECT:
+		m := map[string]interface{}{}
+		for _, k := range v.Keys() {
+			m[k] = toInterface(t, v.Get(k))
+		}
+		return m
+	case BEGIN_ARRAY:
+		s := make([]interface{}, v.Len())
+		for i := range s {
+			s[i] = toInterface(t, v.Index(i))
+		}
+		return s
+	case NUMBER:
+		f, err := v.Float64()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return f
+	case STRING:
+		s, err := v.String()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	case BOOLEAN:
+		return string(v.Raw()) == "true"
+	}
+	return nil
+}
+
+func TestValueDatasets(t *testing.T) {
+	entries, _ := os.ReadDir("./testdata")
+	for _, ent := range entries {
+		if ent.IsDir() {
+			continue
+		}
+		file, _ := os.ReadFile(path.Join("./testdata", ent.Name()))
+		v, err := Parse(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var want interface{}
+		if err := json.Unmarshal(file, &want); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(toInterface(t, v), want) {
+			t.Errorf("%s: mismatch with encoding/json", ent.Name())
+		}
+	}
+}